@@ -37,6 +37,8 @@ const (
 	
 	ATTR_MULTIPLAYER_SESSION_ID = "multiplayer.session.id"
 	
+	ATTR_MULTIPLAYER_SESSION_STOP_REASON = "multiplayer.session.stop.reason"
+	
 	ATTR_MULTIPLAYER_HTTP_PROXY = "multiplayer.http.proxy"
 	
 	ATTR_MULTIPLAYER_HTTP_PROXY_TYPE = "multiplayer.http.proxy.type"
@@ -74,4 +76,22 @@ const (
 	MAX_MASK_DEPTH = 8
 	
 	MULTIPLAYER_MAX_HTTP_REQUEST_RESPONSE_SIZE = 50000
+	
+	ENV_MULTIPLAYER_API_KEY = "MULTIPLAYER_API_KEY"
+	
+	ENV_MULTIPLAYER_API_BASE_URL = "MULTIPLAYER_API_BASE_URL"
+	
+	ENV_MULTIPLAYER_OTLP_ENDPOINT = "MULTIPLAYER_OTLP_ENDPOINT"
+	
+	ENV_MULTIPLAYER_GENERATE_SESSION_SHORT_ID_LOCALLY = "MULTIPLAYER_GENERATE_SESSION_SHORT_ID_LOCALLY"
+	
+	ENV_MULTIPLAYER_REPORT_PAUSE_RESUME = "MULTIPLAYER_REPORT_PAUSE_RESUME"
+	
+	ENV_MULTIPLAYER_OFFLINE_JOURNAL_PATH = "MULTIPLAYER_OFFLINE_JOURNAL_PATH"
+	
+	ENV_MULTIPLAYER_MAX_SESSION_DURATION = "MULTIPLAYER_MAX_SESSION_DURATION"
+	
+	ENV_MULTIPLAYER_IDLE_TIMEOUT = "MULTIPLAYER_IDLE_TIMEOUT"
+	
+	MULTIPLAYER_SESSION_RECORDER_VERSION = "1.0.0"
 )
@@ -14,11 +14,6 @@ var (
 	COMPONENT_VERSION = getEnv("COMPONENT_VERSION", "0.0.1")
 	ENVIRONMENT       = getEnv("ENVIRONMENT", "staging")
 	
-	MULTIPLAYER_API_KEY = getRequiredEnv("MULTIPLAYER_API_KEY")
-	
-	OTLP_TRACES_ENDPOINT = getEnv("OTLP_TRACES_ENDPOINT", "https://api.multiplayer.app/v1/traces")
-	OTLP_LOGS_ENDPOINT   = getEnv("OTLP_LOGS_ENDPOINT", "https://api.multiplayer.app/v1/logs")
-	
 	MULTIPLAYER_OTLP_SPAN_RATIO = getSpanRatio()
 	
 	// Service URLs
@@ -35,14 +30,6 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
-func getRequiredEnv(key string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		panic("Environment variable " + key + " is required but not set")
-	}
-	return value
-}
-
 func getLogLevel() string {
 	if isProduction {
 		return getEnv("LOG_LEVEL", "info")
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"time"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
@@ -13,26 +12,14 @@ import (
 )
 
 func main() {
-	// Initialize session recorder
-	sr := session_recorder.NewSessionRecorder()
-
-	// Get trace ID generator from OpenTelemetry setup
-	idGenerator := getIdGenerator()
-
-	config := session_recorder.SessionRecorderConfig{
-		APIKey:           MULTIPLAYER_API_KEY,
-		TraceIDGenerator: idGenerator,
-		ResourceAttributes: map[string]interface{}{
-			"componentName":    COMPONENT_NAME,
-			"componentVersion": COMPONENT_VERSION,
-			"environment":      ENVIRONMENT,
-		},
-	}
+	ctx := context.Background()
 
-	err := sr.Init(config)
+	// Set up OpenTelemetry and the session recorder
+	pipeline, err := setupOpenTelemetry(ctx)
 	if err != nil {
-		log.Fatalf("Failed to initialize session recorder: %v", err)
+		log.Fatalf("Failed to set up OpenTelemetry: %v", err)
 	}
+	sr := pipeline.SessionRecorder
 
 	// Start a debug session
 	session := &session_recorder.Session{
@@ -50,7 +37,6 @@ func main() {
 	fmt.Println("Debug session started successfully")
 
 	// Simulate some work
-	ctx := context.Background()
 	doSomeWork(ctx)
 
 	// Stop the session
@@ -61,12 +47,16 @@ func main() {
 
 	fmt.Println("Debug session stopped successfully")
 
-	// Wait for telemetry to be exported
+	// Flush and export the remaining telemetry
 	fmt.Println("Waiting for telemetry export...")
-	time.Sleep(15 * time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := pipeline.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down: %v", err)
+	}
 
 	fmt.Println("Exiting...")
-	os.Exit(0)
 }
 
 // doSomeWork simulates application work with tracing
@@ -6,59 +6,35 @@ import (
 	"os"
 	"runtime"
 
-	"github.com/multiplayer-app/multiplayer-otlp-go/exporters"
-	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
-	"go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	otelTrace "go.opentelemetry.io/otel/trace"
 )
 
-var idGenerator *multiplayer.SessionRecorderIdGenerator
-var tracerProvider *trace.TracerProvider
-
-func init() {
-	setupOpenTelemetry()
-}
-
-func setupOpenTelemetry() {
-	// Create resource
-	res := getResource()
-	
-	// Create trace exporter
-	traceExporter, err := exporters.NewSessionRecorderHttpTraceExporter(MULTIPLAYER_API_KEY, OTLP_TRACES_ENDPOINT)
-	if err != nil {
-		log.Fatalf("Failed to create trace exporter: %v", err)
-	}
-	
-	// Create ID generator
-	idGenerator = multiplayer.NewSessionRecorderIdGenerator()
-	
-	// Create sampler
-	sampler := multiplayer.NewSampler(trace.TraceIDRatioBased(MULTIPLAYER_OTLP_SPAN_RATIO))
-	
-	// Create trace provider
-	tracerProvider = trace.NewTracerProvider(
-		trace.WithResource(res),
-		trace.WithBatcher(traceExporter),
-		trace.WithSampler(sampler),
-		trace.WithIDGenerator(idGenerator),
+// setupOpenTelemetry creates the tracer and logger providers and the session
+// recorder. The API key and endpoint are read from MULTIPLAYER_API_KEY and
+// MULTIPLAYER_OTLP_ENDPOINT.
+func setupOpenTelemetry(ctx context.Context) (*multiplayer.Pipeline, error) {
+	return multiplayer.Setup(ctx,
+		multiplayer.WithResource(getResource()),
+		multiplayer.WithSampleRatio(MULTIPLAYER_OTLP_SPAN_RATIO),
+		multiplayer.WithSessionRecorderOptions(
+			session_recorder.WithResourceAttributes(map[string]interface{}{
+				"componentName":    COMPONENT_NAME,
+				"componentVersion": COMPONENT_VERSION,
+				"environment":      ENVIRONMENT,
+			}),
+		),
 	)
-	
-	// Create log exporter (commented out for now due to API differences)
-	// logExporter := exporters.NewSessionRecorderHttpLogsExporter(MULTIPLAYER_API_KEY, OTLP_LOGS_ENDPOINT)
-	
-	// Set global providers
-	otel.SetTracerProvider(tracerProvider)
-	otel.SetTextMapPropagator(propagation.TraceContext{})
 }
 
 func getResource() *resource.Resource {
 	hostname, _ := os.Hostname()
-	
+
 	res, err := resource.New(
 		context.Background(),
 		resource.WithAttributes(
@@ -75,29 +51,16 @@ func getResource() *resource.Resource {
 		resource.WithContainer(),
 		resource.WithHost(),
 	)
-	
+
 	if err != nil {
 		log.Printf("Failed to create resource: %v", err)
 		return resource.Default()
 	}
-	
-	return res
-}
 
-// getIdGenerator returns the global ID generator for session recorder
-func getIdGenerator() *multiplayer.SessionRecorderIdGenerator {
-	return idGenerator
+	return res
 }
 
 // getTracer returns a tracer for the application
 func getTracer() otelTrace.Tracer {
 	return otel.Tracer(COMPONENT_NAME)
 }
-
-// shutdown gracefully shuts down the OpenTelemetry providers
-func shutdown(ctx context.Context) error {
-	if tracerProvider != nil {
-		return tracerProvider.Shutdown(ctx)
-	}
-	return nil
-}
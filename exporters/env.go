@@ -0,0 +1,29 @@
+package exporters
+
+import (
+	"os"
+	"strings"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+)
+
+// apiKeyOrEnv returns apiKey, or MULTIPLAYER_API_KEY when apiKey is empty
+func apiKeyOrEnv(apiKey string) string {
+	if apiKey == "" {
+		return os.Getenv(constants.ENV_MULTIPLAYER_API_KEY)
+	}
+	return apiKey
+}
+
+// endpointOrEnv returns the endpoint passed to an exporter constructor. Without
+// one it falls back to MULTIPLAYER_OTLP_ENDPOINT followed by path, and then to
+// defaultURL.
+func endpointOrEnv(endpoint []string, path string, defaultURL string) string {
+	if len(endpoint) > 0 && endpoint[0] != "" {
+		return endpoint[0]
+	}
+	if base := os.Getenv(constants.ENV_MULTIPLAYER_OTLP_ENDPOINT); base != "" {
+		return strings.TrimRight(base, "/") + path
+	}
+	return defaultURL
+}
@@ -0,0 +1,112 @@
+package exportertest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// TB is the part of testing.TB used by the assertions
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Attribute returns the value of a span attribute
+func Attribute(span sdktrace.ReadOnlySpan, key string) (attribute.Value, bool) {
+	for _, attr := range span.Attributes() {
+		if string(attr.Key) == key {
+			return attr.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// AssertMasked checks that the span attribute key, e.g. a captured request
+// body, was masked. Every given field of the JSON value must hold the mask
+// placeholder wherever it appears; without fields, the value must contain
+// the placeholder somewhere. Fields are compared case-insensitively so header
+// names match in any case.
+func AssertMasked(t TB, span sdktrace.ReadOnlySpan, key string, fields ...string) bool {
+	t.Helper()
+
+	value, ok := Attribute(span, key)
+	if !ok {
+		t.Errorf("span %q has no attribute %s", span.Name(), key)
+		return false
+	}
+	text := value.Emit()
+
+	if len(fields) == 0 {
+		if !strings.Contains(text, constants.MASK_PLACEHOLDER) {
+			t.Errorf("attribute %s of span %q is not masked: %s", key, span.Name(), text)
+			return false
+		}
+		return true
+	}
+
+	var data interface{}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		t.Errorf("attribute %s of span %q is not JSON: %v", key, span.Name(), err)
+		return false
+	}
+
+	passed := true
+	for _, field := range fields {
+		values := findField(data, field)
+		if len(values) == 0 {
+			t.Errorf("attribute %s of span %q has no field %q", key, span.Name(), field)
+			passed = false
+			continue
+		}
+		for _, v := range values {
+			if v != constants.MASK_PLACEHOLDER {
+				t.Errorf("field %q of attribute %s of span %q is not masked: %v", field, key, span.Name(), v)
+				passed = false
+			}
+		}
+	}
+	return passed
+}
+
+// findField returns the values of every field with the given name at any
+// depth of a decoded JSON value
+func findField(data interface{}, field string) []interface{} {
+	var values []interface{}
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if strings.EqualFold(k, field) {
+				values = append(values, value)
+			} else {
+				values = append(values, findField(value, field)...)
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			values = append(values, findField(value, field)...)
+		}
+	}
+	return values
+}
+
+// AssertNoMultiplayerAttributes checks that no span carries an attribute
+// with the multiplayer. prefix, e.g. after the spans went through
+// SessionRecorderTraceExporterWrapper
+func AssertNoMultiplayerAttributes(t TB, spans ...sdktrace.ReadOnlySpan) bool {
+	t.Helper()
+
+	passed := true
+	for _, span := range spans {
+		for _, attr := range span.Attributes() {
+			if strings.HasPrefix(string(attr.Key), constants.MULTIPLAYER_ATTRIBUTE_PREFIX) {
+				t.Errorf("span %q has attribute %s", span.Name(), attr.Key)
+				passed = false
+			}
+		}
+	}
+	return passed
+}
@@ -0,0 +1,141 @@
+// Package exportertest provides in-memory doubles of the Multiplayer exporters
+// and assertions on the spans and log records they keep.
+package exportertest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// inSession reports whether the trace belongs to the session with the given
+// short id
+func inSession(traceID trace.TraceID, shortSessionID string) bool {
+	_, id, ok := sdk.ClassifyTrace(traceID.String())
+	return ok && id == shortSessionID
+}
+
+// TraceExporter keeps every exported span in memory. Use SpansForSession for
+// the spans SessionRecorderHttpTraceExporter would send to Multiplayer. It is
+// safe for concurrent use.
+type TraceExporter struct {
+	mutex sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+var _ sdktrace.SpanExporter = &TraceExporter{}
+
+func NewTraceExporter() *TraceExporter {
+	return &TraceExporter{}
+}
+
+func (e *TraceExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *TraceExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the exported spans in export order
+func (e *TraceExporter) Spans() []sdktrace.ReadOnlySpan {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]sdktrace.ReadOnlySpan(nil), e.spans...)
+}
+
+// SpansForSession returns the exported spans of the session with the given
+// short id
+func (e *TraceExporter) SpansForSession(shortSessionID string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, span := range e.Spans() {
+		if inSession(span.SpanContext().TraceID(), shortSessionID) {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// SpanByName returns the first exported span with the given name
+func (e *TraceExporter) SpanByName(name string) (sdktrace.ReadOnlySpan, bool) {
+	for _, span := range e.Spans() {
+		if span.Name() == name {
+			return span, true
+		}
+	}
+	return nil, false
+}
+
+// Reset forgets the exported spans
+func (e *TraceExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.spans = nil
+}
+
+// LogsExporter keeps every exported log record in memory. Use
+// RecordsForSession for the records SessionRecorderHttpLogsExporter would send
+// to Multiplayer. It is safe for concurrent use.
+type LogsExporter struct {
+	mutex   sync.Mutex
+	records []sdklog.Record
+}
+
+var _ sdklog.Exporter = &LogsExporter{}
+
+func NewLogsExporter() *LogsExporter {
+	return &LogsExporter{}
+}
+
+func (e *LogsExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for i := range records {
+		// records are only valid during the call
+		e.records = append(e.records, records[i].Clone())
+	}
+	return nil
+}
+
+func (e *LogsExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *LogsExporter) ForceFlush(ctx context.Context) error {
+	return nil
+}
+
+// Records returns the exported log records in export order
+func (e *LogsExporter) Records() []sdklog.Record {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+// RecordsForSession returns the exported log records of the session with the
+// given short id
+func (e *LogsExporter) RecordsForSession(shortSessionID string) []sdklog.Record {
+	var records []sdklog.Record
+	for _, record := range e.Records() {
+		if inSession(record.TraceID(), shortSessionID) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Reset forgets the exported log records
+func (e *LogsExporter) Reset() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.records = nil
+}
@@ -0,0 +1,154 @@
+package exportertest_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters"
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters/exportertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const shortSessionID = "0123456789abcdef"
+
+// recorder records the failures of an assertion
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+// record emits a span named "other" outside of a session and a span named
+// "session" within one, each with a log record carrying attrs
+func record(t *testing.T, spanExporter sdktrace.SpanExporter, logsExporter sdklog.Exporter, attrs ...attribute.KeyValue) {
+	t.Helper()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSyncer(spanExporter),
+	)
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(logsExporter)))
+
+	for _, name := range []string{"other", "session"} {
+		if name == "session" {
+			idGenerator.SetSessionId(shortSessionID, types.SESSION_TYPE_MANUAL)
+		}
+		ctx, span := tp.Tracer("test").Start(context.Background(), name, trace.WithAttributes(attrs...))
+		var r log.Record
+		r.SetBody(log.StringValue(name))
+		for _, attr := range attrs {
+			r.AddAttributes(log.String(string(attr.Key), attr.Value.Emit()))
+		}
+		lp.Logger("test").Emit(ctx, r)
+		span.End()
+	}
+}
+
+func TestTraceExporter(t *testing.T) {
+	spans := exportertest.NewTraceExporter()
+	record(t, spans, exportertest.NewLogsExporter())
+
+	if n := len(spans.Spans()); n != 2 {
+		t.Fatalf("expected every span to be kept, got %d", n)
+	}
+	recorded := spans.SpansForSession(shortSessionID)
+	if len(recorded) != 1 || recorded[0].Name() != "session" {
+		t.Errorf("expected the session span only, got %d spans", len(recorded))
+	}
+	if span, ok := spans.SpanByName("other"); !ok || span.Name() != "other" {
+		t.Error("expected SpanByName to find the span outside of the session")
+	}
+
+	spans.Reset()
+	if n := len(spans.Spans()); n != 0 {
+		t.Errorf("expected no span after Reset, got %d", n)
+	}
+}
+
+func TestLogsExporter(t *testing.T) {
+	records := exportertest.NewLogsExporter()
+	record(t, exportertest.NewTraceExporter(), records)
+
+	if n := len(records.Records()); n != 2 {
+		t.Fatalf("expected every log record to be kept, got %d", n)
+	}
+	recorded := records.RecordsForSession(shortSessionID)
+	if len(recorded) != 1 || recorded[0].Body().AsString() != "session" {
+		t.Errorf("expected the session log record only, got %d records", len(recorded))
+	}
+
+	records.Reset()
+	if n := len(records.Records()); n != 0 {
+		t.Errorf("expected no log record after Reset, got %d", n)
+	}
+}
+
+func TestAssertNoMultiplayerAttributes(t *testing.T) {
+	attrs := []attribute.KeyValue{
+		attribute.String(constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, "{}"),
+		attribute.String("http.method", "POST"),
+	}
+
+	spans := exportertest.NewTraceExporter()
+	record(t, spans, exportertest.NewLogsExporter(), attrs...)
+	r := &recorder{}
+	if exportertest.AssertNoMultiplayerAttributes(r, spans.Spans()...) || len(r.errors) != 2 {
+		t.Errorf("expected the multiplayer attribute of both spans to be reported, got %v", r.errors)
+	}
+
+	// spans outside of sessions go through the wrapper too
+	others := exportertest.NewTraceExporter()
+	record(t, exporters.NewSessionRecorderTraceExporterWrapper(others), exportertest.NewLogsExporter(), attrs...)
+	if n := len(others.Spans()); n != 2 {
+		t.Fatalf("expected both spans exported through the wrapper, got %d", n)
+	}
+	exportertest.AssertNoMultiplayerAttributes(t, others.Spans()...)
+	if _, ok := exportertest.Attribute(others.Spans()[0], "http.method"); !ok {
+		t.Error("expected other attributes to be kept")
+	}
+}
+
+func TestAssertMasked(t *testing.T) {
+	spans := exportertest.NewTraceExporter()
+	record(t, spans, exportertest.NewLogsExporter(),
+		attribute.String(constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, `{"user":{"Password":"`+constants.MASK_PLACEHOLDER+`","name":"alice"}}`),
+	)
+	span, ok := spans.SpanByName("session")
+	if !ok {
+		t.Fatal("no session span")
+	}
+
+	exportertest.AssertMasked(t, span, constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY)
+	exportertest.AssertMasked(t, span, constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, "password")
+
+	tests := []struct {
+		name   string
+		key    string
+		fields []string
+	}{
+		{"unmasked field", constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, []string{"name"}},
+		{"missing field", constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, []string{"token"}},
+		{"missing attribute", constants.ATTR_MULTIPLAYER_HTTP_RESPONSE_BODY, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			if exportertest.AssertMasked(r, span, tt.key, tt.fields...) || len(r.errors) != 1 {
+				t.Errorf("expected a single failure, got %v", r.errors)
+			}
+		})
+	}
+}
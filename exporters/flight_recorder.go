@@ -0,0 +1,277 @@
+package exporters
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// defaultFlightRecorderWindow matches the window saved by the backend
+	defaultFlightRecorderWindow = 90 * time.Second
+
+	defaultFlightRecorderMaxBytes = 16 << 20
+
+	// defaultFlightRecorderMaxBatchBytes keeps flushed batches well below the
+	// 4 MiB message limit of gRPC servers
+	defaultFlightRecorderMaxBatchBytes = 1 << 20
+)
+
+type FlightRecorderConfig struct {
+	// Window is how long spans and log records are kept, 90 seconds by default
+	Window time.Duration
+	// MaxBytes bounds the estimated size of the buffer, 16 MiB by default.
+	// The oldest items are dropped first.
+	MaxBytes int
+	// MaxBatchBytes bounds the estimated size of every export made by Flush,
+	// 1 MiB by default
+	MaxBatchBytes int
+}
+
+func (c FlightRecorderConfig) withDefaults() FlightRecorderConfig {
+	if c.Window <= 0 {
+		c.Window = defaultFlightRecorderWindow
+	}
+	if c.MaxBytes <= 0 {
+		c.MaxBytes = defaultFlightRecorderMaxBytes
+	}
+	if c.MaxBatchBytes <= 0 {
+		c.MaxBatchBytes = defaultFlightRecorderMaxBatchBytes
+	}
+	return c
+}
+
+type ringEntry[T any] struct {
+	item    T
+	addedAt time.Time
+	size    int
+}
+
+// ringBuffer keeps the items added within a time window, up to a total size
+type ringBuffer[T any] struct {
+	mutex         sync.Mutex
+	window        time.Duration
+	maxBytes      int
+	maxBatchBytes int
+	entries       []ringEntry[T]
+	size          int
+}
+
+func newRingBuffer[T any](config FlightRecorderConfig) *ringBuffer[T] {
+	return &ringBuffer[T]{
+		window:        config.Window,
+		maxBytes:      config.MaxBytes,
+		maxBatchBytes: config.MaxBatchBytes,
+	}
+}
+
+func (b *ringBuffer[T]) add(item T, size int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.entries = append(b.entries, ringEntry[T]{
+		item:    item,
+		addedAt: time.Now(),
+		size:    size,
+	})
+	b.size += size
+	b.evict()
+}
+
+// drain removes and returns the items within the window, in batches of at
+// most maxBatchBytes. An item larger than that gets a batch of its own.
+func (b *ringBuffer[T]) drain() [][]T {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.evict()
+	var batches [][]T
+	var batch []T
+	batchSize := 0
+	for _, entry := range b.entries {
+		if len(batch) > 0 && batchSize+entry.size > b.maxBatchBytes {
+			batches = append(batches, batch)
+			batch = nil
+			batchSize = 0
+		}
+		batch = append(batch, entry.item)
+		batchSize += entry.size
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	b.entries = nil
+	b.size = 0
+	return batches
+}
+
+func (b *ringBuffer[T]) clear() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.entries = nil
+	b.size = 0
+}
+
+// evict drops items that are too old or over the size limit.
+// It must be called with b.mutex held.
+func (b *ringBuffer[T]) evict() {
+	cutoff := time.Now().Add(-b.window)
+	dropped := 0
+	for dropped < len(b.entries) && (b.size > b.maxBytes || b.entries[dropped].addedAt.Before(cutoff)) {
+		b.size -= b.entries[dropped].size
+		dropped++
+	}
+	if dropped > 0 {
+		// copy so the dropped items can be garbage collected
+		b.entries = append([]ringEntry[T](nil), b.entries[dropped:]...)
+	}
+}
+
+func isContinuousTraceID(traceID string) bool {
+	sessionType, _, ok := sdk.ClassifyTrace(traceID)
+	return ok && sessionType == types.SESSION_TYPE_CONTINUOUS
+}
+
+// FlightRecorderTraceExporter keeps spans of continuous traces in memory and
+// exports the latest window only when Flush is called, e.g. before a
+// continuous session is saved. Other spans are exported right away.
+type FlightRecorderTraceExporter struct {
+	exporter trace.SpanExporter
+	buffer   *ringBuffer[trace.ReadOnlySpan]
+}
+
+var _ trace.SpanExporter = &FlightRecorderTraceExporter{}
+
+func NewFlightRecorderTraceExporter(exporter trace.SpanExporter, config FlightRecorderConfig) *FlightRecorderTraceExporter {
+	return &FlightRecorderTraceExporter{
+		exporter: exporter,
+		buffer:   newRingBuffer[trace.ReadOnlySpan](config.withDefaults()),
+	}
+}
+
+func (e *FlightRecorderTraceExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	var exported []trace.ReadOnlySpan
+
+	for _, span := range spans {
+		if isContinuousTraceID(span.SpanContext().TraceID().String()) {
+			e.buffer.add(span, spanSize(span))
+		} else {
+			exported = append(exported, span)
+		}
+	}
+
+	if len(exported) > 0 {
+		return e.exporter.ExportSpans(ctx, exported)
+	}
+
+	return nil
+}
+
+// Flush exports the buffered spans in batches of at most MaxBatchBytes and
+// empties the buffer. A failed batch does not stop the next ones.
+func (e *FlightRecorderTraceExporter) Flush(ctx context.Context) error {
+	var errs []error
+	for _, spans := range e.buffer.drain() {
+		errs = append(errs, e.exporter.ExportSpans(ctx, spans))
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown drops the buffered spans and shuts down the wrapped exporter
+func (e *FlightRecorderTraceExporter) Shutdown(ctx context.Context) error {
+	e.buffer.clear()
+	return e.exporter.Shutdown(ctx)
+}
+
+// FlightRecorderLogsExporter keeps log records of continuous traces in memory
+// and exports the latest window only when Flush is called. Other log records
+// are exported right away.
+type FlightRecorderLogsExporter struct {
+	exporter LogsExporter
+	buffer   *ringBuffer[sdklog.Record]
+}
+
+func NewFlightRecorderLogsExporter(exporter LogsExporter, config FlightRecorderConfig) *FlightRecorderLogsExporter {
+	return &FlightRecorderLogsExporter{
+		exporter: exporter,
+		buffer:   newRingBuffer[sdklog.Record](config.withDefaults()),
+	}
+}
+
+func (e *FlightRecorderLogsExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	var exported []sdklog.Record
+
+	for i := range records {
+		if isContinuousTraceID(records[i].TraceID().String()) {
+			// records are only valid during the call
+			e.buffer.add(records[i].Clone(), recordSize(&records[i]))
+		} else {
+			exported = append(exported, records[i])
+		}
+	}
+
+	if len(exported) > 0 {
+		return e.exporter.Export(ctx, exported)
+	}
+
+	return nil
+}
+
+// Flush exports the buffered log records in batches of at most MaxBatchBytes
+// and empties the buffer. A failed batch does not stop the next ones.
+func (e *FlightRecorderLogsExporter) Flush(ctx context.Context) error {
+	var errs []error
+	for _, records := range e.buffer.drain() {
+		errs = append(errs, e.exporter.Export(ctx, records))
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown drops the buffered log records and shuts down the wrapped exporter
+func (e *FlightRecorderLogsExporter) Shutdown(ctx context.Context) error {
+	e.buffer.clear()
+	return e.exporter.Shutdown(ctx)
+}
+
+func (e *FlightRecorderLogsExporter) ForceFlush(ctx context.Context) error {
+	return e.exporter.ForceFlush(ctx)
+}
+
+// spanSize estimates the memory used by a span
+func spanSize(span trace.ReadOnlySpan) int {
+	size := 128 + len(span.Name()) + attributesSize(span.Attributes())
+	for _, event := range span.Events() {
+		size += 32 + len(event.Name) + attributesSize(event.Attributes)
+	}
+	for _, link := range span.Links() {
+		size += 64 + attributesSize(link.Attributes)
+	}
+	return size
+}
+
+func attributesSize(attrs []attribute.KeyValue) int {
+	size := 0
+	for _, attr := range attrs {
+		size += len(attr.Key) + len(attr.Value.Emit())
+	}
+	return size
+}
+
+// recordSize estimates the memory used by a log record
+func recordSize(record *sdklog.Record) int {
+	size := 128 + len(record.Body().String())
+	record.WalkAttributes(func(kv log.KeyValue) bool {
+		size += len(kv.Key) + len(kv.Value.String())
+		return true
+	})
+	return size
+}
@@ -0,0 +1,76 @@
+package exporters_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters"
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters/exportertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// batchExporter records the size of every export
+type batchExporter struct {
+	exportertest.TraceExporter
+	batches []int
+}
+
+func (e *batchExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.batches = append(e.batches, len(spans))
+	return e.TraceExporter.ExportSpans(ctx, spans)
+}
+
+func continuousSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	traceID, err := multiplayer.Encode(types.SESSION_TYPE_CONTINUOUS, "0123456789abcdef")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tracetest.SpanStub{
+		Name: name,
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: traceID,
+			SpanID:  trace.SpanID{1},
+		}),
+	}.Snapshot()
+}
+
+func TestFlightRecorderFlushBatches(t *testing.T) {
+	inner := &batchExporter{}
+	// spans named "span" are estimated at 132 bytes, 3 fit in a batch
+	exporter := exporters.NewFlightRecorderTraceExporter(inner, exporters.FlightRecorderConfig{
+		MaxBatchBytes: 400,
+	})
+
+	spans := make([]sdktrace.ReadOnlySpan, 10)
+	for i := range spans {
+		spans[i] = continuousSpan(t, "span")
+	}
+	if err := exporter.ExportSpans(context.Background(), spans); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.batches) != 0 {
+		t.Fatalf("expected continuous spans to be buffered, got exports %v", inner.batches)
+	}
+
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{3, 3, 3, 1}; !slices.Equal(inner.batches, want) {
+		t.Errorf("expected batches %v, got %v", want, inner.batches)
+	}
+
+	// the buffer is empty once flushed
+	if err := exporter.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.batches) != 4 {
+		t.Errorf("expected no export of an empty buffer, got %v", inner.batches)
+	}
+}
@@ -2,27 +2,23 @@ package exporters
 
 import (
 	"context"
-	"strings"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
 	"go.opentelemetry.io/otel/sdk/log"
 )
 
 type SessionRecorderGrpcLogsExporter struct {
-	exporter      *otlploggrpc.Exporter
-	debugPrefixes []string
+	exporter *otlploggrpc.Exporter
 }
 
 func NewSessionRecorderGrpcLogsExporter(apiKey string, endpoint ...string) (*SessionRecorderGrpcLogsExporter, error) {
 	headers := map[string]string{
-		"Authorization": apiKey,
+		"Authorization": apiKeyOrEnv(apiKey),
 	}
 
-	endpointURL := constants.MULTIPLAYER_OTEL_DEFAULT_LOGS_EXPORTER_GRPC_URL
-	if len(endpoint) > 0 && endpoint[0] != "" {
-		endpointURL = endpoint[0]
-	}
+	endpointURL := endpointOrEnv(endpoint, "/v1/logs", constants.MULTIPLAYER_OTEL_DEFAULT_LOGS_EXPORTER_GRPC_URL)
 
 	exporter, err := otlploggrpc.New(context.Background(),
 		otlploggrpc.WithEndpointURL(endpointURL),
@@ -34,10 +30,6 @@ func NewSessionRecorderGrpcLogsExporter(apiKey string, endpoint ...string) (*Ses
 
 	return &SessionRecorderGrpcLogsExporter{
 		exporter: exporter,
-		debugPrefixes: []string{
-			constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX,
-			constants.MULTIPLAYER_TRACE_DEBUG_PREFIX,
-		},
 	}, nil
 }
 
@@ -45,19 +37,7 @@ func (e *SessionRecorderGrpcLogsExporter) Export(ctx context.Context, records []
 	var filteredRecords []log.Record
 
 	for _, record := range records {
-		traceID := record.TraceID().String()
-		shouldExport := false
-
-		if traceID != "00000000000000000000000000000000" {
-			for _, prefix := range e.debugPrefixes {
-				if strings.HasPrefix(traceID, prefix) {
-					shouldExport = true
-					break
-				}
-			}
-		}
-
-		if shouldExport {
+		if sdk.IsMultiplayerTrace(record.TraceID().String()) {
 			filteredRecords = append(filteredRecords, record)
 		}
 	}
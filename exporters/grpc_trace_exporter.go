@@ -2,9 +2,9 @@ package exporters
 
 import (
 	"context"
-	"strings"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -12,18 +12,14 @@ import (
 
 type SessionRecorderGrpcTraceExporter struct {
 	exporter *otlptrace.Exporter
-	debugPrefixes []string
 }
 
 func NewSessionRecorderGrpcTraceExporter(apiKey string, endpoint ...string) (*SessionRecorderGrpcTraceExporter, error) {
 	headers := map[string]string{
-		"Authorization": apiKey,
+		"Authorization": apiKeyOrEnv(apiKey),
 	}
 
-	endpointURL := constants.MULTIPLAYER_OTEL_DEFAULT_TRACES_EXPORTER_GRPC_URL
-	if len(endpoint) > 0 && endpoint[0] != "" {
-		endpointURL = endpoint[0]
-	}
+	endpointURL := endpointOrEnv(endpoint, "/v1/traces", constants.MULTIPLAYER_OTEL_DEFAULT_TRACES_EXPORTER_GRPC_URL)
 
 	client := otlptracegrpc.NewClient(
 		otlptracegrpc.WithEndpointURL(endpointURL),
@@ -37,29 +33,15 @@ func NewSessionRecorderGrpcTraceExporter(apiKey string, endpoint ...string) (*Se
 
 	return &SessionRecorderGrpcTraceExporter{
 		exporter: exporter,
-		debugPrefixes: []string{
-			constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX,
-			constants.MULTIPLAYER_TRACE_DEBUG_PREFIX,
-		},
 	}, nil
 }
 
-// ExportSpans exports spans that have trace IDs starting with debug prefixes
+// ExportSpans exports spans of manual and continuous session traces
 func (e *SessionRecorderGrpcTraceExporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
 	var filteredSpans []trace.ReadOnlySpan
 
 	for _, span := range spans {
-		traceID := span.SpanContext().TraceID().String()
-		shouldExport := false
-
-		for _, prefix := range e.debugPrefixes {
-			if strings.HasPrefix(traceID, prefix) {
-				shouldExport = true
-				break
-			}
-		}
-
-		if shouldExport {
+		if sdk.IsMultiplayerTrace(span.SpanContext().TraceID().String()) {
 			filteredSpans = append(filteredSpans, span)
 		}
 	}
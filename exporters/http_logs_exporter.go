@@ -2,27 +2,23 @@ package exporters
 
 import (
 	"context"
-	"strings"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
 	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/sdk/log"
 )
 
 type SessionRecorderHttpLogsExporter struct {
-	exporter      *otlploghttp.Exporter
-	debugPrefixes []string
+	exporter *otlploghttp.Exporter
 }
 
 func NewSessionRecorderHttpLogsExporter(apiKey string, endpoint ...string) (*SessionRecorderHttpLogsExporter, error) {
 	headers := map[string]string{
-		"Authorization": apiKey,
+		"Authorization": apiKeyOrEnv(apiKey),
 	}
 
-	endpointURL := constants.MULTIPLAYER_OTEL_DEFAULT_LOGS_EXPORTER_HTTP_URL
-	if len(endpoint) > 0 && endpoint[0] != "" {
-		endpointURL = endpoint[0]
-	}
+	endpointURL := endpointOrEnv(endpoint, "/v1/logs", constants.MULTIPLAYER_OTEL_DEFAULT_LOGS_EXPORTER_HTTP_URL)
 
 	exporter, err := otlploghttp.New(context.Background(),
 		otlploghttp.WithEndpointURL(endpointURL),
@@ -34,31 +30,15 @@ func NewSessionRecorderHttpLogsExporter(apiKey string, endpoint ...string) (*Ses
 
 	return &SessionRecorderHttpLogsExporter{
 		exporter: exporter,
-		debugPrefixes: []string{
-			constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX,
-			constants.MULTIPLAYER_TRACE_DEBUG_PREFIX,
-		},
 	}, nil
 }
 
-// Export exports log records that have trace IDs starting with debug prefixes
+// Export exports log records of manual and continuous session traces
 func (e *SessionRecorderHttpLogsExporter) Export(ctx context.Context, records []log.Record) error {
 	var filteredRecords []log.Record
 
 	for _, record := range records {
-		traceID := record.TraceID().String()
-		shouldExport := false
-
-		if traceID != "00000000000000000000000000000000" {
-			for _, prefix := range e.debugPrefixes {
-				if strings.HasPrefix(traceID, prefix) {
-					shouldExport = true
-					break
-				}
-			}
-		}
-
-		if shouldExport {
+		if sdk.IsMultiplayerTrace(record.TraceID().String()) {
 			filteredRecords = append(filteredRecords, record)
 		}
 	}
@@ -59,10 +59,7 @@ func WithResponseData(next http.Handler, options MiddlewareOptions) http.Handler
 			return
 		}
 
-		isDebugTrace := sdk.IsDebugTrace(traceId)
-		if isDebugTrace {
-			w.Header().Set("X-Trace-Id", traceId)
-		}
+		w.Header().Set("X-Trace-Id", traceId)
 
 		rww := NewResponseWriterWrapper(w)
 		defer func() {
@@ -119,7 +116,7 @@ func processBody(bodyBytes []byte, options MiddlewareOptions, span trace.Span) s
 	}
 	
 	if options.IsMaskBodyEnabled != nil && *options.IsMaskBodyEnabled {
-		if sdk.IsDebugTrace(span.SpanContext().TraceID().String()) {
+		if sdk.IsMultiplayerTrace(span.SpanContext().TraceID().String()) {
 			return body
 		}
 	}
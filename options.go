@@ -0,0 +1,113 @@
+package multiplayer
+
+import (
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Protocol is the OTLP protocol used to export to Multiplayer
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http"
+	ProtocolGRPC Protocol = "grpc"
+)
+
+type config struct {
+	protocol               Protocol
+	apiKey                 string
+	tracesEndpoint         string
+	logsEndpoint           string
+	sampleRatio            float64
+	resource               *resource.Resource
+	secondaryTraceExporter sdktrace.SpanExporter
+	secondaryLogsExporter  sdklog.Exporter
+	recorderOptions        []session_recorder.Option
+	global                 bool
+}
+
+func newConfig(opts []Option) config {
+	c := config{
+		protocol:    ProtocolHTTP,
+		sampleRatio: 1,
+		global:      true,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Option configures Setup
+type Option func(*config)
+
+// WithProtocol selects OTLP over HTTP, the default, or gRPC for the
+// Multiplayer exporters
+func WithProtocol(protocol Protocol) Option {
+	return func(c *config) {
+		c.protocol = protocol
+	}
+}
+
+// WithAPIKey sets the Multiplayer API key of the exporters and the session
+// recorder. MULTIPLAYER_API_KEY is used when it is not set.
+func WithAPIKey(apiKey string) Option {
+	return func(c *config) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithEndpoints sets the OTLP endpoints of the Multiplayer exporters. An empty
+// endpoint falls back to MULTIPLAYER_OTLP_ENDPOINT, then to the default
+// Multiplayer endpoint of the protocol.
+func WithEndpoints(tracesEndpoint, logsEndpoint string) Option {
+	return func(c *config) {
+		c.tracesEndpoint = tracesEndpoint
+		c.logsEndpoint = logsEndpoint
+	}
+}
+
+// WithSampleRatio sets the ratio of traces sampled outside of sessions, 1 by
+// default. Session traces are always sampled.
+func WithSampleRatio(ratio float64) Option {
+	return func(c *config) {
+		c.sampleRatio = ratio
+	}
+}
+
+// WithResource sets the resource of the tracer and logger providers
+func WithResource(res *resource.Resource) Option {
+	return func(c *config) {
+		c.resource = res
+	}
+}
+
+// WithSecondaryExporters also exports to another backend, e.g. a collector or
+// an observability platform. Multiplayer attributes are removed from the
+// spans before export, log records are exported unchanged. Either exporter
+// may be nil.
+func WithSecondaryExporters(traceExporter sdktrace.SpanExporter, logsExporter sdklog.Exporter) Option {
+	return func(c *config) {
+		c.secondaryTraceExporter = traceExporter
+		c.secondaryLogsExporter = logsExporter
+	}
+}
+
+// WithSessionRecorderOptions configures the session recorder. The trace id
+// generator and the providers are always set by Setup, as is the API key when
+// WithAPIKey is used, and override these options.
+func WithSessionRecorderOptions(opts ...session_recorder.Option) Option {
+	return func(c *config) {
+		c.recorderOptions = append(c.recorderOptions, opts...)
+	}
+}
+
+// WithGlobal controls whether the providers and the propagator are registered
+// as the OpenTelemetry globals, true by default
+func WithGlobal(global bool) Option {
+	return func(c *config) {
+		c.global = global
+	}
+}
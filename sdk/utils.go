@@ -4,6 +4,9 @@ import (
 	"strings"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/trace"
 )
 
 
@@ -20,6 +23,20 @@ func IsDebugTrace(traceId string) bool {
 }
 
 
+// IsMultiplayerTrace reports whether the trace belongs to a manual or
+// continuous session
 func IsMultiplayerTrace(traceId string) bool {
-	return IsDebugTrace(traceId)
+	_, _, ok := ClassifyTrace(traceId)
+	return ok
+}
+
+// ClassifyTrace returns the type and the short id of the session a trace id
+// was generated for, see the codec of the trace package. ok is false for
+// traces recorded outside of sessions.
+func ClassifyTrace(traceId string) (sessionType types.SessionType, shortSessionId string, ok bool) {
+	traceID, err := trace.TraceIDFromHex(traceId)
+	if err != nil {
+		return 0, "", false
+	}
+	return multiplayer.Decode(traceID)
 }
@@ -4,18 +4,38 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
+// defaultAPITimeout limits every request attempt when no http.Client is given
+const defaultAPITimeout = 30 * time.Second
+
 // APIServiceConfig holds the configuration for the API service
 type APIServiceConfig struct {
 	APIKey              string
 	APIBaseURL          string
 	ContinuousRecording bool
+	Retry               RetryConfig
+	// HTTPClient sends the requests. Transport and Timeout are ignored when
+	// it is set.
+	HTTPClient *http.Client
+	// Transport is used by the default client, e.g. for proxies or custom CAs
+	Transport http.RoundTripper
+	// Timeout limits every request attempt of the default client, 30 seconds
+	// by default
+	Timeout time.Duration
+	// UserAgent is put in front of the User-Agent of the SDK
+	UserAgent string
+	// Headers are added to every request
+	Headers map[string]string
 }
 
 type Tag struct {
@@ -24,6 +44,7 @@ type Tag struct {
 }
 
 type StartSessionRequest struct {
+	ShortID            string                 `json:"shortId,omitempty"`
 	Name               string                 `json:"name,omitempty"`
 	ResourceAttributes map[string]interface{} `json:"resourceAttributes,omitempty"`
 	SessionAttributes  map[string]interface{} `json:"sessionAttributes,omitempty"`
@@ -34,6 +55,14 @@ type StopSessionRequest struct {
 	SessionAttributes map[string]interface{} `json:"sessionAttributes,omitempty"`
 }
 
+// UpdateSessionRequest changes the metadata of a running session. Session
+// attributes and tags are merged into the ones the session already has.
+type UpdateSessionRequest struct {
+	Name              string                 `json:"name,omitempty"`
+	SessionAttributes map[string]interface{} `json:"sessionAttributes,omitempty"`
+	Tags              []Tag                  `json:"tags,omitempty"`
+}
+
 type CheckRemoteSessionResponse struct {
 	State string `json:"state"` // "START" or "STOP"
 }
@@ -48,7 +77,7 @@ func NewAPIService() *APIService {
 		config: APIServiceConfig{
 			APIBaseURL: constants.MULTIPLAYER_BASE_API_URL,
 		},
-		client: &http.Client{},
+		client: newHTTPClient(APIServiceConfig{}),
 	}
 }
 
@@ -56,15 +85,41 @@ func (a *APIService) Init(config APIServiceConfig) {
 	if config.APIBaseURL == "" {
 		config.APIBaseURL = constants.MULTIPLAYER_BASE_API_URL
 	}
-	
+
 	a.config = config
+	a.client = newHTTPClient(config)
+}
+
+func newHTTPClient(config APIServiceConfig) *http.Client {
+	if config.HTTPClient != nil {
+		return config.HTTPClient
+	}
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = defaultAPITimeout
+	}
+
+	return &http.Client{
+		Transport: config.Transport,
+		Timeout:   timeout,
+	}
+}
+
+// userAgent returns the User-Agent header of API requests
+func (a *APIService) userAgent() string {
+	userAgent := "multiplayer-session-recorder-go/" + constants.MULTIPLAYER_SESSION_RECORDER_VERSION
+	if a.config.UserAgent != "" {
+		return a.config.UserAgent + " " + userAgent
+	}
+	return userAgent
 }
 
 func (a *APIService) UpdateConfigs(config APIServiceConfig) {
 	if config.APIBaseURL == "" {
 		config.APIBaseURL = constants.MULTIPLAYER_BASE_API_URL
 	}
-	
+
 	if config.APIKey != "" {
 		a.config.APIKey = config.APIKey
 	}
@@ -72,6 +127,21 @@ func (a *APIService) UpdateConfigs(config APIServiceConfig) {
 		a.config.APIBaseURL = config.APIBaseURL
 	}
 	a.config.ContinuousRecording = config.ContinuousRecording
+	if !config.Retry.isZero() {
+		a.config.Retry = config.Retry
+	}
+	if config.UserAgent != "" {
+		a.config.UserAgent = config.UserAgent
+	}
+	if config.Headers != nil {
+		a.config.Headers = config.Headers
+	}
+	if config.HTTPClient != nil || config.Transport != nil || config.Timeout > 0 {
+		a.config.HTTPClient = config.HTTPClient
+		a.config.Transport = config.Transport
+		a.config.Timeout = config.Timeout
+		a.client = newHTTPClient(a.config)
+	}
 }
 
 func (a *APIService) GetAPIBaseURL() string {
@@ -82,126 +152,279 @@ func (a *APIService) GetAPIBaseURL() string {
 }
 
 func (a *APIService) StartSession(requestBody Session) (*Session, error) {
+	return a.StartSessionContext(context.Background(), requestBody)
+}
+
+func (a *APIService) StartSessionContext(ctx context.Context, requestBody Session) (*Session, error) {
 	req := StartSessionRequest{
+		ShortID:            requestBody.ShortID,
 		Name:               requestBody.Name,
 		ResourceAttributes: requestBody.ResourceAttributes,
 		SessionAttributes:  requestBody.SessionAttributes,
 		Tags:               convertToTags(requestBody.Tags),
 	}
-	
+
 	var response Session
-	err := a.makeRequest("/debug-sessions/start", "POST", req, &response)
+	err := a.makeRequest(ctx, "/debug-sessions/start", "POST", req, &response)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &response, nil
 }
 
 func (a *APIService) StopSession(sessionID string, requestBody Session) error {
+	return a.StopSessionContext(context.Background(), sessionID, requestBody)
+}
+
+func (a *APIService) StopSessionContext(ctx context.Context, sessionID string, requestBody Session) error {
 	req := StopSessionRequest{
 		SessionAttributes: requestBody.SessionAttributes,
 	}
-	
-	return a.makeRequest(fmt.Sprintf("/debug-sessions/%s/stop", sessionID), "PATCH", req, nil)
+
+	return a.makeRequest(ctx, fmt.Sprintf("/debug-sessions/%s/stop", sessionID), "PATCH", req, nil)
 }
 
 func (a *APIService) CancelSession(sessionID string) error {
-	return a.makeRequest(fmt.Sprintf("/debug-sessions/%s/cancel", sessionID), "DELETE", nil, nil)
+	return a.CancelSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) CancelSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/debug-sessions/%s/cancel", sessionID), "DELETE", nil, nil)
+}
+
+func (a *APIService) PauseSession(sessionID string) error {
+	return a.PauseSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) PauseSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/debug-sessions/%s/pause", sessionID), "PATCH", nil, nil)
+}
+
+func (a *APIService) ResumeSession(sessionID string) error {
+	return a.ResumeSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) ResumeSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/debug-sessions/%s/resume", sessionID), "PATCH", nil, nil)
+}
+
+func (a *APIService) UpdateSession(sessionID string, requestBody Session) error {
+	return a.UpdateSessionContext(context.Background(), sessionID, requestBody)
+}
+
+func (a *APIService) UpdateSessionContext(ctx context.Context, sessionID string, requestBody Session) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/debug-sessions/%s", sessionID), "PATCH", newUpdateSessionRequest(requestBody), nil)
 }
 
 func (a *APIService) StartContinuousSession(requestBody Session) (*Session, error) {
+	return a.StartContinuousSessionContext(context.Background(), requestBody)
+}
+
+func (a *APIService) StartContinuousSessionContext(ctx context.Context, requestBody Session) (*Session, error) {
 	req := StartSessionRequest{
+		ShortID:            requestBody.ShortID,
 		Name:               requestBody.Name,
 		ResourceAttributes: requestBody.ResourceAttributes,
 		SessionAttributes:  requestBody.SessionAttributes,
 		Tags:               convertToTags(requestBody.Tags),
 	}
-	
+
 	var response Session
-	err := a.makeRequest("/continuous-debug-sessions/start", "POST", req, &response)
+	err := a.makeRequest(ctx, "/continuous-debug-sessions/start", "POST", req, &response)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &response, nil
 }
 
 func (a *APIService) SaveContinuousSession(sessionID string, requestBody Session) error {
+	return a.SaveContinuousSessionContext(context.Background(), sessionID, requestBody)
+}
+
+func (a *APIService) SaveContinuousSessionContext(ctx context.Context, sessionID string, requestBody Session) error {
 	req := StartSessionRequest{
 		Name:               requestBody.Name,
 		ResourceAttributes: requestBody.ResourceAttributes,
 		SessionAttributes:  requestBody.SessionAttributes,
 		Tags:               convertToTags(requestBody.Tags),
 	}
-	
-	return a.makeRequest(fmt.Sprintf("/continuous-debug-sessions/%s/save", sessionID), "POST", req, nil)
+
+	return a.makeRequest(ctx, fmt.Sprintf("/continuous-debug-sessions/%s/save", sessionID), "POST", req, nil)
 }
 
 func (a *APIService) StopContinuousSession(sessionID string) error {
-	return a.makeRequest(fmt.Sprintf("/continuous-debug-sessions/%s/cancel", sessionID), "DELETE", nil, nil)
+	return a.StopContinuousSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) StopContinuousSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/continuous-debug-sessions/%s/cancel", sessionID), "DELETE", nil, nil)
+}
+
+func (a *APIService) PauseContinuousSession(sessionID string) error {
+	return a.PauseContinuousSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) PauseContinuousSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/continuous-debug-sessions/%s/pause", sessionID), "PATCH", nil, nil)
+}
+
+func (a *APIService) ResumeContinuousSession(sessionID string) error {
+	return a.ResumeContinuousSessionContext(context.Background(), sessionID)
+}
+
+func (a *APIService) ResumeContinuousSessionContext(ctx context.Context, sessionID string) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/continuous-debug-sessions/%s/resume", sessionID), "PATCH", nil, nil)
+}
+
+func (a *APIService) UpdateContinuousSession(sessionID string, requestBody Session) error {
+	return a.UpdateContinuousSessionContext(context.Background(), sessionID, requestBody)
+}
+
+func (a *APIService) UpdateContinuousSessionContext(ctx context.Context, sessionID string, requestBody Session) error {
+	return a.makeRequest(ctx, fmt.Sprintf("/continuous-debug-sessions/%s", sessionID), "PATCH", newUpdateSessionRequest(requestBody), nil)
+}
+
+func newUpdateSessionRequest(requestBody Session) UpdateSessionRequest {
+	return UpdateSessionRequest{
+		Name:              requestBody.Name,
+		SessionAttributes: requestBody.SessionAttributes,
+		Tags:              convertToTags(requestBody.Tags),
+	}
 }
 
 func (a *APIService) CheckRemoteSession(requestBody Session) (*CheckRemoteSessionResponse, error) {
+	return a.CheckRemoteSessionContext(context.Background(), requestBody)
+}
+
+func (a *APIService) CheckRemoteSessionContext(ctx context.Context, requestBody Session) (*CheckRemoteSessionResponse, error) {
 	req := StartSessionRequest{
 		Name:               requestBody.Name,
 		ResourceAttributes: requestBody.ResourceAttributes,
 		SessionAttributes:  requestBody.SessionAttributes,
 		Tags:               convertToTags(requestBody.Tags),
 	}
-	
+
 	var response CheckRemoteSessionResponse
-	err := a.makeRequest("/remote-debug-session/check", "POST", req, &response)
+	err := a.makeRequest(ctx, "/remote-debug-session/check", "POST", req, &response)
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &response, nil
 }
 
-func (a *APIService) makeRequest(path, method string, body interface{}, response interface{}) error {
+// makeRequest sends a request to the Multiplayer API. The context controls
+// cancellation and deadlines of the request and its trace context is
+// propagated through the request headers. Failed attempts are retried as
+// configured by APIServiceConfig.Retry, every attempt carries the same
+// Idempotency-Key header.
+func (a *APIService) makeRequest(ctx context.Context, path, method string, body interface{}, response interface{}) error {
 	url := fmt.Sprintf("%s/v0/radar%s", a.GetAPIBaseURL(), path)
-	
-	var bodyReader io.Reader
+
+	var bodyBytes []byte
 	if body != nil {
-		bodyBytes, err := json.Marshal(body)
+		var err error
+		bodyBytes, err = json.Marshal(body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
+	}
+
+	retry := a.config.Retry.withDefaults()
+	idempotencyKey := idempotencyKeyFromContext(ctx)
+
+	for attempt := 1; ; attempt++ {
+		err := a.doRequest(ctx, url, path, method, bodyBytes, idempotencyKey, response)
+		if err == nil || attempt >= retry.MaxAttempts || !isRetryableError(ctx, err) {
+			return err
+		}
+
+		delay := retry.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = min(apiErr.RetryAfter, retry.MaxBackoff)
+		}
+		// give up right away rather than wait past the deadline
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+
+		if retry.OnRetry != nil {
+			retry.OnRetry(RetryAttempt{
+				Method:  method,
+				Path:    path,
+				Attempt: attempt,
+				Delay:   delay,
+				Err:     err,
+			})
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// doRequest makes a single attempt of a request
+func (a *APIService) doRequest(ctx context.Context, url, path, method string, bodyBytes []byte, idempotencyKey string, response interface{}) error {
+	var bodyReader io.Reader
+	if bodyBytes != nil {
 		bodyReader = bytes.NewReader(bodyBytes)
 	}
-	
-	req, err := http.NewRequestWithContext(context.Background(), method, url, bodyReader)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-	
+
+	for key, value := range a.config.Headers {
+		req.Header.Set(key, value)
+	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", a.userAgent())
+	req.Header.Set("Idempotency-Key", idempotencyKey)
 	if a.config.APIKey != "" {
 		req.Header.Set("X-Api-Key", a.config.APIKey)
 	}
-	
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
 	resp, err := a.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
-	
+
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("network response was not ok: %s, body: %s", resp.Status, string(bodyBytes))
+		apiErr := &APIError{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       string(bodyBytes),
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return apiErr
 	}
-	
+
 	if resp.StatusCode == 204 {
 		return nil
 	}
-	
+
 	if response != nil {
 		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
 			return fmt.Errorf("failed to decode response: %w", err)
 		}
 	}
-	
+
 	return nil
 }
 
@@ -209,7 +432,7 @@ func convertToTags(tags map[string]string) []Tag {
 	if tags == nil {
 		return nil
 	}
-	
+
 	result := make([]Tag, 0, len(tags))
 	for key, value := range tags {
 		result = append(result, Tag{
@@ -217,6 +440,6 @@ func convertToTags(tags map[string]string) []Tag {
 			Value: value,
 		})
 	}
-	
+
 	return result
 }
@@ -0,0 +1,376 @@
+package session_recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// fastRetry retries failed requests without waiting
+var fastRetry = session_recorder.RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: time.Millisecond,
+	MaxBackoff:     time.Millisecond,
+}
+
+// assertServerSession fails unless the server knows the session in state
+func assertServerSession(t *testing.T, api *sessionrecordertest.Server, shortSessionID string, state sessionrecordertest.SessionState) sessionrecordertest.Session {
+	t.Helper()
+
+	session, ok := api.Session(shortSessionID)
+	if !ok {
+		t.Fatalf("session %s not found", shortSessionID)
+	}
+	if session.State != state {
+		t.Fatalf("expected session %s to be %s, got %s", shortSessionID, state, session.State)
+	}
+	return session
+}
+
+func assertState(t *testing.T, sr *session_recorder.SessionRecorder, state session_recorder.SessionState) {
+	t.Helper()
+
+	if got := sr.State(); got != state {
+		t.Fatalf("expected session recorder to be %s, got %s", state, got)
+	}
+}
+
+func TestManualSession(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithReportPauseResume(true),
+	)
+
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, &session_recorder.Session{Name: "checkout"}); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+
+	if err := sr.UpdateSession(ctx, &session_recorder.Session{
+		Name:              "checkout failed",
+		SessionAttributes: map[string]interface{}{"cart": "42"},
+		Tags:              map[string]string{"team": "payments"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	session := assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+	if session.Name != "checkout failed" || session.SessionAttributes["cart"] != "42" || session.Tags["team"] != "payments" {
+		t.Errorf("expected the update to reach the session, got %+v", session.Session)
+	}
+
+	if err := sr.PauseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStatePaused)
+	assertState(t, sr, session_recorder.SessionStatePaused)
+
+	if err := sr.ResumeContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+	assertState(t, sr, session_recorder.SessionStateStarted)
+
+	if err := sr.StopContext(ctx, &session_recorder.Session{
+		SessionAttributes: map[string]interface{}{"comment": "done"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	session = assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStopped)
+	if session.SessionAttributes["comment"] != "done" {
+		t.Errorf("expected the stop attributes to reach the session, got %v", session.SessionAttributes)
+	}
+	assertState(t, sr, session_recorder.SessionStateStopped)
+	if id := sr.ShortSessionID(); id != "" {
+		t.Errorf("expected no short session id once stopped, got %q", id)
+	}
+}
+
+func TestCancelSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionType types.SessionType
+		endpoint    sessionrecordertest.Endpoint
+	}{
+		{"manual", types.SESSION_TYPE_MANUAL, sessionrecordertest.EndpointCancelSession},
+		{"continuous", types.SESSION_TYPE_CONTINUOUS, sessionrecordertest.EndpointCancelContinuousSession},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := sessionrecordertest.NewServer()
+			defer api.Close()
+
+			ctx := context.Background()
+			sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+
+			if err := sr.StartContext(ctx, tt.sessionType, nil); err != nil {
+				t.Fatal(err)
+			}
+			shortSessionID := sr.ShortSessionID()
+
+			if err := sr.CancelContext(ctx); err != nil {
+				t.Fatal(err)
+			}
+			assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateCanceled)
+			assertState(t, sr, session_recorder.SessionStateStopped)
+			if calls := api.CallsTo(tt.endpoint); len(calls) != 1 {
+				t.Errorf("expected a single call to %s, got %d", tt.endpoint, len(calls))
+			}
+		})
+	}
+}
+
+func TestContinuousSession(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithReportPauseResume(true),
+	)
+
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+
+	if err := sr.UpdateSession(ctx, &session_recorder.Session{Tags: map[string]string{"team": "payments"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.SaveContext(ctx, &session_recorder.Session{Name: "first save"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sr.PauseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStatePaused)
+	if err := sr.ResumeContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a stop with session data saves the session one last time
+	if err := sr.StopContext(ctx, &session_recorder.Session{Name: "last save"}); err != nil {
+		t.Fatal(err)
+	}
+	session := assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateCanceled)
+	if session.Tags["team"] != "payments" {
+		t.Errorf("expected the update to reach the session, got %v", session.Tags)
+	}
+	if len(session.Saves) != 2 || session.Saves[0].Name != "first save" || session.Saves[1].Name != "last save" {
+		t.Errorf("expected 2 saves, got %+v", session.Saves)
+	}
+	assertState(t, sr, session_recorder.SessionStateStopped)
+}
+
+func TestCheckRemoteContinuousSession(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+
+	// STOP leaves a stopped session recorder alone
+	if err := sr.CheckRemoteContinuousSessionContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStopped)
+
+	api.SetRemoteState("START")
+	if err := sr.CheckRemoteContinuousSessionContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStarted)
+	if sessionType := sr.SessionType(); sessionType != types.SESSION_TYPE_CONTINUOUS {
+		t.Fatalf("expected a continuous session, got %d", sessionType)
+	}
+	shortSessionID := sr.ShortSessionID()
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+
+	// START keeps the session that is already recording
+	if err := sr.CheckRemoteContinuousSessionContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.CallsTo(sessionrecordertest.EndpointStartContinuousSession); len(calls) != 1 {
+		t.Errorf("expected a single start, got %d", len(calls))
+	}
+
+	api.SetRemoteState("STOP")
+	if err := sr.CheckRemoteContinuousSessionContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStopped)
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateCanceled)
+}
+
+func TestStopRejected(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		// state is the state of the session recorder after the failed stop
+		state session_recorder.SessionState
+	}{
+		// the session does not exist anymore, there is nothing left to stop
+		{"not found", http.StatusNotFound, session_recorder.SessionStateStopped},
+		// the backend may still be recording the session
+		{"conflict", http.StatusConflict, session_recorder.SessionStateStarted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := sessionrecordertest.NewServer()
+			defer api.Close()
+
+			ctx := context.Background()
+			sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+
+			if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+				t.Fatal(err)
+			}
+			shortSessionID := sr.ShortSessionID()
+
+			api.Fail(sessionrecordertest.EndpointStopSession, tt.statusCode, 1)
+			err := sr.StopContext(ctx, nil)
+			var apiErr *session_recorder.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.statusCode {
+				t.Fatalf("expected an API error with status %d, got %v", tt.statusCode, err)
+			}
+			if calls := api.CallsTo(sessionrecordertest.EndpointStopSession); len(calls) != 1 {
+				t.Errorf("expected %d not to be retried, got %d calls", tt.statusCode, len(calls))
+			}
+			assertState(t, sr, tt.state)
+
+			if tt.state == session_recorder.SessionStateStarted {
+				if sr.ShortSessionID() != shortSessionID {
+					t.Fatalf("expected session %s to be kept, got %q", shortSessionID, sr.ShortSessionID())
+				}
+				if err := sr.StopContext(ctx, nil); err != nil {
+					t.Fatal(err)
+				}
+				assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStopped)
+				assertState(t, sr, session_recorder.SessionStateStopped)
+			}
+		})
+	}
+}
+
+// errorListener sends the events of failed operations
+type errorListener struct {
+	session_recorder.NopSessionListener
+	errors chan session_recorder.SessionEvent
+}
+
+func (l errorListener) OnError(ctx context.Context, event session_recorder.SessionEvent, err error) {
+	l.errors <- event
+}
+
+func TestStartConflict(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	listener := errorListener{errors: make(chan session_recorder.SessionEvent, 1)}
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithGenerateSessionShortIDLocally(true),
+		session_recorder.WithSessionShortIDGenerator(func() string { return "0123456789abcdef" }),
+		session_recorder.WithListeners(listener),
+	)
+
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.StopContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second session is recorded locally until the API rejects its id
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case event := <-listener.errors:
+		if event.Operation != session_recorder.SessionOperationStart || event.Session.ShortID != "0123456789abcdef" {
+			t.Errorf("expected the start of session 0123456789abcdef to fail, got %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the registration to fail")
+	}
+	assertState(t, sr, session_recorder.SessionStateStopped)
+	if calls := api.CallsTo(sessionrecordertest.EndpointStartSession); len(calls) != 2 || calls[1].StatusCode != http.StatusConflict {
+		t.Errorf("expected the second start to conflict, got %+v", calls)
+	}
+}
+
+func TestRetryFailedRequests(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithRetry(fastRetry),
+	)
+
+	api.Fail(sessionrecordertest.EndpointStartContinuousSession, http.StatusServiceUnavailable, 2)
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	if calls := api.CallsTo(sessionrecordertest.EndpointStartContinuousSession); len(calls) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(calls))
+	}
+
+	api.Fail(sessionrecordertest.EndpointSaveContinuousSession, http.StatusInternalServerError, -1)
+	err := sr.SaveContext(ctx, nil)
+	var apiErr *session_recorder.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected an API error with status 500, got %v", err)
+	}
+	if calls := api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession); len(calls) != fastRetry.MaxAttempts {
+		t.Errorf("expected %d attempts, got %d", fastRetry.MaxAttempts, len(calls))
+	}
+	assertState(t, sr, session_recorder.SessionStateStarted)
+}
+
+func TestSlowAPI(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+	api.SetLatency(200 * time.Millisecond)
+
+	// the session recorder stays STARTING while the request is in flight
+	started := make(chan error, 1)
+	go func() {
+		started <- sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil)
+	}()
+	waitFor(t, time.Second, func() bool {
+		return sr.State() == session_recorder.SessionStateStarting
+	})
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); !errors.Is(err, session_recorder.ErrSessionActive) {
+		t.Errorf("expected ErrSessionActive while starting, got %v", err)
+	}
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStarted)
+
+	// a stop that times out keeps the session
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sr.StopContext(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the stop to time out, got %v", err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStarted)
+}
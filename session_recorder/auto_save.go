@@ -0,0 +1,115 @@
+package session_recorder
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// defaultAutoSaveDebounce is used when no debounce is given to
+// NewAutoSaveSpanProcessor
+const defaultAutoSaveDebounce = 5 * time.Second
+
+// AutoSaveSpanProcessor saves the continuous session of a session recorder
+// when a span of a continuous trace ends with the
+// ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE attribute set. Spans flagged
+// within the debounce window of the first one result in a single save.
+type AutoSaveSpanProcessor struct {
+	sr       *SessionRecorder
+	debounce time.Duration
+
+	mutex   sync.Mutex
+	timer   *time.Timer
+	reasons []string
+}
+
+var _ sdktrace.SpanProcessor = &AutoSaveSpanProcessor{}
+
+// NewAutoSaveSpanProcessor returns a span processor that saves the session
+// of sr. A debounce of 0 uses the default of 5 seconds.
+func NewAutoSaveSpanProcessor(sr *SessionRecorder, debounce time.Duration) *AutoSaveSpanProcessor {
+	if debounce <= 0 {
+		debounce = defaultAutoSaveDebounce
+	}
+
+	return &AutoSaveSpanProcessor{
+		sr:       sr,
+		debounce: debounce,
+	}
+}
+
+func (p *AutoSaveSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+func (p *AutoSaveSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !isContinuousTrace(s.SpanContext().TraceID()) {
+		return
+	}
+
+	autoSave := false
+	reason := ""
+	for _, attr := range s.Attributes() {
+		switch string(attr.Key) {
+		case constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE:
+			autoSave = attr.Value.AsBool()
+		case constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON:
+			reason = attr.Value.Emit()
+		}
+	}
+	if !autoSave {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if reason != "" && !slices.Contains(p.reasons, reason) {
+		p.reasons = append(p.reasons, reason)
+	}
+	if p.timer == nil {
+		p.timer = time.AfterFunc(p.debounce, func() {
+			p.flush(context.Background())
+		})
+	}
+}
+
+// flush saves the session if a save is pending
+func (p *AutoSaveSpanProcessor) flush(ctx context.Context) error {
+	p.mutex.Lock()
+	if p.timer == nil {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.timer.Stop()
+	p.timer = nil
+	reasons := p.reasons
+	p.reasons = nil
+	p.mutex.Unlock()
+
+	sessionData := &Session{}
+	if len(reasons) > 0 {
+		sessionData.SessionAttributes = map[string]interface{}{
+			constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON: strings.Join(reasons, ", "),
+		}
+	}
+
+	return p.sr.save(ctx, SessionTriggerAutoSave, sessionData)
+}
+
+// ForceFlush saves the session right away if a save is pending, unless it is
+// called by a save
+func (p *AutoSaveSpanProcessor) ForceFlush(ctx context.Context) error {
+	if isSaving(ctx) {
+		return nil
+	}
+	return p.flush(ctx)
+}
+
+// Shutdown saves the session if a save is pending
+func (p *AutoSaveSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.flush(ctx)
+}
@@ -0,0 +1,137 @@
+package session_recorder_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newAutoSaveTracer returns a tracer whose spans go through an auto-save
+// span processor of sr
+func newAutoSaveTracer(t *testing.T, sr *session_recorder.SessionRecorder, idGenerator *multiplayer.SessionRecorderIdGenerator, debounce time.Duration) (*sdktrace.TracerProvider, trace.Tracer) {
+	t.Helper()
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSpanProcessor(session_recorder.NewAutoSaveSpanProcessor(sr, debounce)),
+	)
+	t.Cleanup(func() { tp.Shutdown(context.Background()) })
+	return tp, tp.Tracer("test")
+}
+
+func endSpan(tracer trace.Tracer, attrs ...attribute.KeyValue) {
+	_, span := tracer.Start(context.Background(), "work", trace.WithAttributes(attrs...))
+	span.End()
+}
+
+func autoSave(reason string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Bool(constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE, true),
+		attribute.String(constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON, reason),
+	}
+}
+
+func TestAutoSaveSpanProcessorDebounces(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator)
+	_, tracer := newAutoSaveTracer(t, sr, idGenerator, 100*time.Millisecond)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+
+	endSpan(tracer, autoSave("payment failed")...)
+	endSpan(tracer)
+	endSpan(tracer, autoSave("cart expired")...)
+	endSpan(tracer, autoSave("payment failed")...)
+
+	waitFor(t, 2*time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession)) > 0
+	})
+	// later spans of the window would have been saved by now
+	time.Sleep(200 * time.Millisecond)
+
+	if saves := api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession); len(saves) != 1 {
+		t.Fatalf("expected a single save, got %d", len(saves))
+	}
+	session, ok := api.Session(shortSessionID)
+	if !ok {
+		t.Fatalf("session %s not found", shortSessionID)
+	}
+	reason := session.Saves[0].SessionAttributes[constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON]
+	if want := "payment failed, cart expired"; reason != want {
+		t.Errorf("expected reason %q, got %v", want, reason)
+	}
+}
+
+func TestAutoSaveSpanProcessorIgnoresSpans(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionType types.SessionType
+		attrs       []attribute.KeyValue
+	}{
+		{"manual session", types.SESSION_TYPE_MANUAL, autoSave("payment failed")},
+		{"unflagged span", types.SESSION_TYPE_CONTINUOUS, nil},
+		{"auto-save disabled", types.SESSION_TYPE_CONTINUOUS, []attribute.KeyValue{
+			attribute.Bool(constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE, false),
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := sessionrecordertest.NewServer()
+			defer api.Close()
+
+			idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+			sr := newSessionRecorder(t, api, idGenerator)
+			tp, tracer := newAutoSaveTracer(t, sr, idGenerator, time.Hour)
+
+			if err := sr.StartContext(context.Background(), tt.sessionType, nil); err != nil {
+				t.Fatal(err)
+			}
+			endSpan(tracer, tt.attrs...)
+
+			// ForceFlush saves right away if a save is pending
+			if err := tp.ForceFlush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if saves := api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession); len(saves) != 0 {
+				t.Errorf("expected no save, got %d", len(saves))
+			}
+		})
+	}
+}
+
+func TestAutoSaveSpanProcessorForceFlush(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator)
+	tp, tracer := newAutoSaveTracer(t, sr, idGenerator, time.Hour)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	endSpan(tracer, autoSave("payment failed")...)
+
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if saves := api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession); len(saves) != 1 {
+		t.Errorf("expected the pending save to be made, got %d saves", len(saves))
+	}
+}
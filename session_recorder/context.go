@@ -0,0 +1,24 @@
+package session_recorder
+
+import (
+	"context"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// WithSession returns a copy of ctx bound to the session recorded by sr.
+// Root traces started from the returned context are tagged with that session
+// while it is started, and are never tagged with any other session.
+func WithSession(ctx context.Context, sr *SessionRecorder) context.Context {
+	return types.ContextWithSession(ctx, sr)
+}
+
+// SessionFromContext returns the session recorder bound to ctx with WithSession
+func SessionFromContext(ctx context.Context) (*SessionRecorder, bool) {
+	session, ok := types.SessionFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	sr, ok := session.(*SessionRecorder)
+	return sr, ok
+}
@@ -0,0 +1,82 @@
+package session_recorder_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startTrace starts and ends a root span from ctx and returns the short id of
+// the session its trace is tagged with
+func startTrace(tracer trace.Tracer, ctx context.Context) string {
+	_, span := tracer.Start(ctx, "root")
+	span.End()
+	_, shortSessionID, _ := multiplayer.Decode(span.SpanContext().TraceID())
+	return shortSessionID
+}
+
+func TestContextScopedSession(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator, session_recorder.WithContextScoped(true))
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithIDGenerator(idGenerator)).Tracer("test")
+
+	ctx := context.Background()
+	boundCtx := session_recorder.WithSession(ctx, sr)
+	if bound, ok := session_recorder.SessionFromContext(boundCtx); !ok || bound != sr {
+		t.Fatal("expected the session recorder bound to the context")
+	}
+
+	if got := startTrace(tracer, boundCtx); got != "" {
+		t.Errorf("expected untagged traces before the session started, got session %s", got)
+	}
+
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+
+	if got := startTrace(tracer, boundCtx); got != shortSessionID {
+		t.Errorf("expected traces of the bound context tagged with %s, got %q", shortSessionID, got)
+	}
+	if got := startTrace(tracer, ctx); got != "" {
+		t.Errorf("expected other traces untagged, got session %s", got)
+	}
+
+	// spans keep the trace of their parent, whichever context they start from
+	parentCtx, parent := tracer.Start(ctx, "parent")
+	_, child := tracer.Start(session_recorder.WithSession(parentCtx, sr), "child")
+	if child.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Error("expected the child span to stay in the trace of its parent")
+	}
+	child.End()
+	parent.End()
+
+	if err := sr.PauseContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := startTrace(tracer, boundCtx); got != "" {
+		t.Errorf("expected untagged traces while paused, got session %s", got)
+	}
+	if err := sr.ResumeContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := startTrace(tracer, boundCtx); got != shortSessionID {
+		t.Errorf("expected traces of the bound context tagged with %s once resumed, got %q", shortSessionID, got)
+	}
+
+	if err := sr.StopContext(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := startTrace(tracer, boundCtx); got != "" {
+		t.Errorf("expected untagged traces once stopped, got session %s", got)
+	}
+}
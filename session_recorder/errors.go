@@ -0,0 +1,129 @@
+package session_recorder
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	// ErrNotInitialized is returned by actions on a session recorder before Init
+	ErrNotInitialized = errors.New("configuration not initialized. Call Init() before performing any actions")
+
+	// ErrSessionActive is returned when starting a session while another one is active
+	ErrSessionActive = errors.New("session should be ended before starting new one")
+
+	// ErrNoActiveSession is returned by actions that need an active or paused session
+	ErrNoActiveSession = errors.New("session should be active or paused")
+
+	// ErrInvalidSessionType is returned by actions that do not support the session type
+	ErrInvalidSessionType = errors.New("invalid session type")
+
+	// ErrInvalidShortSessionID is returned for short session ids of the wrong
+	// length or that the trace id generator cannot encode
+	ErrInvalidShortSessionID = errors.New("invalid short session id")
+
+	// ErrAPIKeyNotProvided is returned by Init without an API key
+	ErrAPIKeyNotProvided = errors.New("api key not provided")
+
+	// ErrIncompatibleTraceIDGenerator is returned by Init for a missing or unsupported trace id generator
+	ErrIncompatibleTraceIDGenerator = errors.New("incompatible trace id generator")
+
+	// ErrSessionNotStarted is returned when the API did not return a started session
+	ErrSessionNotStarted = errors.New("failed to start session")
+
+	// ErrSessionNotFound is returned by SessionRegistry for unknown short session ids
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrRemoteControlRunning is returned when remote control is started twice
+	ErrRemoteControlRunning = errors.New("remote control already running")
+
+	// ErrInvalidInterval is returned by StartRemoteControl for an interval
+	// that is not positive
+	ErrInvalidInterval = errors.New("remote control interval should be positive")
+
+	// ErrUnauthorized is matched by an APIError with status 401 or 403
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrConflict is matched by an APIError with status 409
+	ErrConflict = errors.New("conflict")
+)
+
+// APIError is returned when the Multiplayer API responds with a non 2xx status
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter is the delay requested by a Retry-After header
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("network response was not ok: %s %s: %s, body: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	}
+	return false
+}
+
+// isSessionEnded reports whether the API rejected a request because the
+// session does not exist. Conflicts keep the session, the backend may still
+// be recording it.
+func isSessionEnded(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// ErrInvalidStateTransition is matched by every StateTransitionError
+var ErrInvalidStateTransition = errors.New("invalid session state transition")
+
+// StateTransitionError is returned when an action would move the session
+// recorder into a state that is not reachable from its current state. Besides
+// ErrInvalidStateTransition it matches ErrSessionActive when starting a
+// session that is not stopped, and ErrNoActiveSession when pausing, resuming
+// or stopping a session that is not active.
+type StateTransitionError struct {
+	From SessionState
+	To   SessionState
+}
+
+func (e *StateTransitionError) Error() string {
+	return fmt.Sprintf("invalid session state transition from %s to %s", e.From, e.To)
+}
+
+func (e *StateTransitionError) Is(target error) bool {
+	switch target {
+	case ErrInvalidStateTransition:
+		return true
+	case ErrSessionActive:
+		return e.To == SessionStateStarting
+	case ErrNoActiveSession:
+		return e.To != SessionStateStarting &&
+			(e.From == SessionStateStopped || e.From == SessionStateStarting || e.From == SessionStateStopping)
+	}
+	return false
+}
+
+// ConfigError reports a misconfigured field of SessionRecorderConfig, or an
+// environment variable that could not be parsed
+type ConfigError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,223 @@
+package session_recorder
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reasons recorded in the ATTR_MULTIPLAYER_SESSION_STOP_REASON session
+// attribute of expired sessions
+const (
+	SessionStopReasonMaxDuration = "max-duration"
+	SessionStopReasonIdleTimeout = "idle-timeout"
+)
+
+// expiryRetryInterval is the delay before stopping an expired session again
+// after the API request failed
+const expiryRetryInterval = 30 * time.Second
+
+// sessionExpiry tracks the limits of a started session. done is closed when
+// the session stops.
+type sessionExpiry struct {
+	done         chan struct{}
+	startedAt    time.Time
+	lastActivity atomic.Int64
+	paused       atomic.Bool
+}
+
+// deadline returns when the session expires and why. ok is false when the
+// session has no limits.
+func (e *sessionExpiry) deadline(maxDuration, idleTimeout time.Duration) (deadline time.Time, reason string, ok bool) {
+	if maxDuration > 0 {
+		deadline, reason, ok = e.startedAt.Add(maxDuration), SessionStopReasonMaxDuration, true
+	}
+	if idleTimeout > 0 {
+		lastActivity := time.Unix(0, e.lastActivity.Load())
+		// paused sessions never tag traces, they are not idle
+		if e.paused.Load() {
+			lastActivity = time.Now()
+		}
+		idleDeadline := lastActivity.Add(idleTimeout)
+		if !ok || idleDeadline.Before(deadline) {
+			deadline, reason, ok = idleDeadline, SessionStopReasonIdleTimeout, true
+		}
+	}
+	return deadline, reason, ok
+}
+
+// watchExpiry starts enforcing MaxSessionDuration and IdleTimeout on the
+// session that was just started
+func (sr *SessionRecorder) watchExpiry() {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.expiry != nil || !sr.isActive() {
+		return
+	}
+
+	idleTimeout := sr.idleTimeout
+	if !sr.activityTracked {
+		idleTimeout = 0
+	}
+	if sr.maxSessionDuration <= 0 && idleTimeout <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expiry := &sessionExpiry{
+		done:      make(chan struct{}),
+		startedAt: now,
+	}
+	expiry.lastActivity.Store(now.UnixNano())
+	sr.expiry = expiry
+
+	go sr.runExpiry(expiry, sr.maxSessionDuration, idleTimeout)
+}
+
+// stopExpiry stops enforcing the limits of the current session.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) stopExpiry() {
+	if sr.expiry != nil {
+		close(sr.expiry.done)
+		sr.expiry = nil
+	}
+}
+
+// pauseExpiry suspends the idle timeout of the current session while it is
+// paused, and starts it over when it resumes.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) pauseExpiry(paused bool) {
+	if sr.expiry == nil {
+		return
+	}
+	if !paused {
+		sr.expiry.lastActivity.Store(time.Now().UnixNano())
+	}
+	sr.expiry.paused.Store(paused)
+}
+
+func (sr *SessionRecorder) runExpiry(expiry *sessionExpiry, maxDuration, idleTimeout time.Duration) {
+	for {
+		deadline, reason, _ := expiry.deadline(maxDuration, idleTimeout)
+		if !sr.waitExpiry(expiry, time.Until(deadline)) {
+			return
+		}
+
+		// spans started while waiting move the idle deadline
+		if deadline, reason, _ = expiry.deadline(maxDuration, idleTimeout); time.Now().Before(deadline) {
+			continue
+		}
+
+		// the session keeps running when the stop fails, try again later
+		for sr.expire(expiry, reason) != nil {
+			if !sr.waitExpiry(expiry, expiryRetryInterval) {
+				return
+			}
+		}
+		return
+	}
+}
+
+// waitExpiry waits for d and reports whether the session is still running
+func (sr *SessionRecorder) waitExpiry(expiry *sessionExpiry, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-expiry.done:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// expire stops a session that reached one of its limits, the reason is
+// recorded in the session attributes. The stop request of continuous sessions
+// has no body, so the reason is sent with an update first, best effort; they
+// are stopped without a final save.
+func (sr *SessionRecorder) expire(expiry *sessionExpiry, reason string) error {
+	sr.mutex.Lock()
+	if sr.expiry != expiry {
+		sr.mutex.Unlock()
+		return nil
+	}
+	sessionType := sr.sessionType
+	sessionAttributes := sr.session.SessionAttributes
+	sr.mutex.Unlock()
+
+	ctx := context.Background()
+	stopReason := Session{
+		SessionAttributes: map[string]interface{}{
+			constants.ATTR_MULTIPLAYER_SESSION_STOP_REASON: reason,
+		},
+	}
+
+	if sessionType == types.SESSION_TYPE_CONTINUOUS {
+		// a failed update is reported to the listeners, it must not keep
+		// the session running
+		sr.update(ctx, SessionTriggerTimeout, &stopReason)
+		return sr.stop(ctx, SessionTriggerTimeout, nil)
+	}
+
+	sessionData := mergeSession(Session{SessionAttributes: sessionAttributes}, stopReason)
+	return sr.stop(ctx, SessionTriggerTimeout, &sessionData)
+}
+
+// recordActivity moves the idle deadline of the session if the trace belongs
+// to it
+func (sr *SessionRecorder) recordActivity(traceID trace.TraceID) {
+	sr.mutex.Lock()
+	expiry := sr.expiry
+	shortSessionID := sr.shortSessionID
+	sessionType := sr.sessionType
+	sr.mutex.Unlock()
+
+	if expiry == nil || shortSessionID == "" {
+		return
+	}
+
+	traceSessionType, traceShortSessionID, ok := sdk.ClassifyTrace(traceID.String())
+	if ok && traceSessionType == sessionType && traceShortSessionID == shortSessionID {
+		expiry.lastActivity.Store(time.Now().UnixNano())
+	}
+}
+
+// IdleSpanProcessor reports the spans of a session to its session recorder,
+// which is needed to enforce IdleTimeout
+type IdleSpanProcessor struct {
+	sr *SessionRecorder
+}
+
+var _ sdktrace.SpanProcessor = &IdleSpanProcessor{}
+
+// NewIdleSpanProcessor returns a span processor for sr. Register it with the
+// tracer provider before starting sessions, IdleTimeout is not enforced
+// without it.
+func NewIdleSpanProcessor(sr *SessionRecorder) *IdleSpanProcessor {
+	sr.mutex.Lock()
+	sr.activityTracked = true
+	sr.mutex.Unlock()
+
+	return &IdleSpanProcessor{sr: sr}
+}
+
+func (p *IdleSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.sr.recordActivity(s.SpanContext().TraceID())
+}
+
+func (p *IdleSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {}
+
+func (p *IdleSpanProcessor) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (p *IdleSpanProcessor) ForceFlush(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package session_recorder_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestMaxSessionDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionType types.SessionType
+		endpoint    sessionrecordertest.Endpoint
+		state       sessionrecordertest.SessionState
+	}{
+		{"manual", types.SESSION_TYPE_MANUAL, sessionrecordertest.EndpointStopSession, sessionrecordertest.SessionStateStopped},
+		{"continuous", types.SESSION_TYPE_CONTINUOUS, sessionrecordertest.EndpointCancelContinuousSession, sessionrecordertest.SessionStateCanceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := sessionrecordertest.NewServer()
+			defer api.Close()
+
+			sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+				session_recorder.WithMaxSessionDuration(50*time.Millisecond),
+			)
+
+			if err := sr.StartContext(context.Background(), tt.sessionType, nil); err != nil {
+				t.Fatal(err)
+			}
+			shortSessionID := sr.ShortSessionID()
+
+			waitFor(t, 2*time.Second, func() bool {
+				return sr.State() == session_recorder.SessionStateStopped
+			})
+
+			if calls := api.CallsTo(tt.endpoint); len(calls) != 1 {
+				t.Fatalf("expected one call to %s, got %d", tt.endpoint, len(calls))
+			}
+			if calls := api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession); len(calls) != 0 {
+				t.Fatalf("expected no save, got %d", len(calls))
+			}
+
+			session, ok := api.Session(shortSessionID)
+			if !ok {
+				t.Fatalf("session %s not found", shortSessionID)
+			}
+			if session.State != tt.state {
+				t.Errorf("expected session %s, got %s", tt.state, session.State)
+			}
+			if reason := session.SessionAttributes[constants.ATTR_MULTIPLAYER_SESSION_STOP_REASON]; reason != session_recorder.SessionStopReasonMaxDuration {
+				t.Errorf("expected stop reason %q, got %v", session_recorder.SessionStopReasonMaxDuration, reason)
+			}
+		})
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator,
+		session_recorder.WithIdleTimeout(100*time.Millisecond),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSpanProcessor(session_recorder.NewIdleSpanProcessor(sr)),
+	)
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+
+	// spans of the session keep it running past the idle timeout
+	for i := 0; i < 10; i++ {
+		_, span := tracer.Start(context.Background(), "work")
+		span.End()
+		time.Sleep(25 * time.Millisecond)
+	}
+	if state := sr.State(); state != session_recorder.SessionStateStarted {
+		t.Fatalf("expected the active session to keep running, got %s", state)
+	}
+
+	waitFor(t, 2*time.Second, func() bool {
+		return sr.State() == session_recorder.SessionStateStopped
+	})
+
+	session, ok := api.Session(shortSessionID)
+	if !ok {
+		t.Fatalf("session %s not found", shortSessionID)
+	}
+	if reason := session.SessionAttributes[constants.ATTR_MULTIPLAYER_SESSION_STOP_REASON]; reason != session_recorder.SessionStopReasonIdleTimeout {
+		t.Errorf("expected stop reason %q, got %v", session_recorder.SessionStopReasonIdleTimeout, reason)
+	}
+}
+
+func TestIdleTimeoutPaused(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator,
+		session_recorder.WithIdleTimeout(100*time.Millisecond),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSpanProcessor(session_recorder.NewIdleSpanProcessor(sr)),
+	)
+	defer tp.Shutdown(context.Background())
+	tracer := tp.Tracer("test")
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.PauseContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// spans of a paused session are not tagged, the session is not idle
+	for i := 0; i < 12; i++ {
+		_, span := tracer.Start(context.Background(), "work")
+		span.End()
+		time.Sleep(25 * time.Millisecond)
+	}
+	assertState(t, sr, session_recorder.SessionStatePaused)
+
+	if err := sr.ResumeContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	resumedAt := time.Now()
+	waitFor(t, 2*time.Second, func() bool {
+		return sr.State() == session_recorder.SessionStateStopped
+	})
+	if idle := time.Since(resumedAt); idle < 100*time.Millisecond {
+		t.Errorf("expected the idle timeout to start over on resume, stopped after %s", idle)
+	}
+}
+
+func TestMaxSessionDurationWithoutUpdate(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithMaxSessionDuration(50*time.Millisecond),
+	)
+	// a backend without the update endpoint
+	api.Fail(sessionrecordertest.EndpointUpdateContinuousSession, http.StatusNotFound, -1)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+
+	waitFor(t, 2*time.Second, func() bool {
+		return sr.State() == session_recorder.SessionStateStopped
+	})
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateCanceled)
+}
@@ -0,0 +1,6 @@
+package session_recorder
+
+// exported for the tests of package session_recorder_test
+var RemoteControlDelay = remoteControlDelay
+
+const MaxRemoteControlBackoff = maxRemoteControlBackoff
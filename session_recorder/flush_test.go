@@ -0,0 +1,107 @@
+package session_recorder_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// orderedExporter records how many saves the backend had received when each
+// span was exported
+type orderedExporter struct {
+	api *sessionrecordertest.Server
+
+	mutex        sync.Mutex
+	savesAtSpans []int
+}
+
+func (e *orderedExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	saves := len(e.api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession))
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for range spans {
+		e.savesAtSpans = append(e.savesAtSpans, saves)
+	}
+	return nil
+}
+
+func (e *orderedExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *orderedExporter) exported() []int {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]int(nil), e.savesAtSpans...)
+}
+
+func TestAutoSaveFlushesProviders(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	inner := &orderedExporter{api: api}
+	flightRecorder := exporters.NewFlightRecorderTraceExporter(inner, exporters.FlightRecorderConfig{})
+
+	// the trigger engine needs the session recorder and the provider needs
+	// the trigger engine, so the session recorder is initialized last
+	sr := session_recorder.NewSessionRecorder()
+	engine := session_recorder.NewTriggerEngine(sr, session_recorder.TriggerEngineConfig{})
+	// spans stay queued in the batch span processor until it is flushed
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSpanProcessor(engine),
+		sdktrace.WithBatcher(flightRecorder, sdktrace.WithBatchTimeout(time.Hour)),
+	)
+	defer tp.Shutdown(context.Background())
+
+	config := session_recorder.SessionRecorderConfig{
+		TraceIDGenerator: idGenerator,
+		Providers:        []session_recorder.Provider{tp},
+		Flushers:         []session_recorder.Flusher{flightRecorder},
+	}
+	for _, opt := range api.Options() {
+		opt(&config)
+	}
+	if err := sr.Init(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tracer := tp.Tracer("test")
+	_, span := tracer.Start(context.Background(), "ok")
+	span.End()
+	_, span = tracer.Start(context.Background(), "failed")
+	span.SetStatus(codes.Error, "boom")
+	span.End()
+
+	// the save force flushes the provider, whose trigger engine must not
+	// wait for the save in progress
+	waitFor(t, 2*time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointSaveContinuousSession)) == 1
+	})
+
+	exported := inner.exported()
+	if len(exported) != 2 {
+		t.Fatalf("expected the 2 queued spans to be exported, got %d", len(exported))
+	}
+	for _, saves := range exported {
+		if saves != 0 {
+			t.Errorf("expected spans to be exported before the save, got %v", exported)
+			break
+		}
+	}
+}
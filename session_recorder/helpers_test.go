@@ -0,0 +1,36 @@
+package session_recorder_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+)
+
+// newSessionRecorder returns a session recorder pointed at api that tags
+// traces through idGenerator
+func newSessionRecorder(t *testing.T, api *sessionrecordertest.Server, idGenerator *multiplayer.SessionRecorderIdGenerator, opts ...session_recorder.Option) *session_recorder.SessionRecorder {
+	t.Helper()
+
+	opts = append(append(api.Options(), session_recorder.WithTraceIDGenerator(idGenerator)), opts...)
+	sr, err := session_recorder.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sr
+}
+
+// waitFor polls condition until it holds or the timeout expires
+func waitFor(t *testing.T, timeout time.Duration, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met within %s", timeout)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
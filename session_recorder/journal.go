@@ -0,0 +1,207 @@
+package session_recorder
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+type journalOperation string
+
+const (
+	journalOperationStart  journalOperation = "start"
+	journalOperationStop   journalOperation = "stop"
+	journalOperationSave   journalOperation = "save"
+	journalOperationCancel journalOperation = "cancel"
+	journalOperationUpdate journalOperation = "update"
+)
+
+// journalEntry is a session operation waiting to be sent to the API.
+// IdempotencyKey is the key the operation was first sent with, if it was.
+type journalEntry struct {
+	Operation      journalOperation  `json:"operation"`
+	SessionType    types.SessionType `json:"sessionType"`
+	ShortID        string            `json:"shortId"`
+	Session        Session           `json:"session"`
+	IdempotencyKey string            `json:"idempotencyKey,omitempty"`
+	CreatedAt      time.Time         `json:"createdAt"`
+}
+
+// journal is a persistent, append only list of session operations stored as
+// JSON lines. Entries are removed from the front once they are replayed.
+type journal struct {
+	mutex sync.Mutex
+	path  string
+	count int
+}
+
+func newJournal(path string) (*journal, error) {
+	j := &journal{path: path}
+
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	j.count = len(entries)
+
+	return j, nil
+}
+
+// pending reports whether the journal holds entries that were not replayed
+func (j *journal) pending() bool {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.count > 0
+}
+
+func (j *journal) append(entry journalEntry) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal journal entry: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+
+	j.count++
+	return nil
+}
+
+func (j *journal) entries() ([]journalEntry, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.read()
+}
+
+// removeFirst drops the first n entries, keeping entries appended since they
+// were read
+func (j *journal) removeFirst(n int) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return err
+	}
+	if n > len(entries) {
+		n = len(entries)
+	}
+	return j.write(entries[n:])
+}
+
+// replaceShortID points every entry for oldShortID to newShortID
+func (j *journal) replaceShortID(oldShortID, newShortID string) error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	entries, err := j.read()
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].ShortID == oldShortID {
+			entries[i].ShortID = newShortID
+			entries[i].Session.ShortID = newShortID
+		}
+	}
+	return j.write(entries)
+}
+
+// read must be called with j.mutex held
+func (j *journal) read() ([]journalEntry, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to parse journal entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	return entries, nil
+}
+
+// write atomically replaces the journal. It must be called with j.mutex held.
+func (j *journal) write(entries []journalEntry) error {
+	if len(entries) == 0 {
+		j.count = 0
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+		return nil
+	}
+
+	tmpPath := j.path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to marshal journal entry: %w", err)
+		}
+		writer.Write(append(line, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		return fmt.Errorf("failed to replace journal: %w", err)
+	}
+
+	j.count = len(entries)
+	return nil
+}
@@ -0,0 +1,107 @@
+package session_recorder
+
+import (
+	"context"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// SessionTrigger tells what caused a session operation
+type SessionTrigger string
+
+const (
+	// SessionTriggerManual is a call made by the application
+	SessionTriggerManual SessionTrigger = "manual"
+	// SessionTriggerRemote is a change requested from the Multiplayer dashboard
+	SessionTriggerRemote SessionTrigger = "remote"
+	// SessionTriggerAutoSave is a save requested by the traces or logs of the application
+	SessionTriggerAutoSave SessionTrigger = "auto-save"
+	// SessionTriggerTimeout is a session ended by the session recorder after it ran too long
+	SessionTriggerTimeout SessionTrigger = "timeout"
+)
+
+// SessionOperation is an operation reported to a SessionListener
+type SessionOperation string
+
+const (
+	SessionOperationStart  SessionOperation = "start"
+	SessionOperationStop   SessionOperation = "stop"
+	SessionOperationSave   SessionOperation = "save"
+	SessionOperationCancel SessionOperation = "cancel"
+	SessionOperationUpdate SessionOperation = "update"
+)
+
+// SessionEvent describes a session operation
+type SessionEvent struct {
+	Operation   SessionOperation
+	Trigger     SessionTrigger
+	SessionType types.SessionType
+	// Session is the session returned by the API. Sessions started locally
+	// or offline carry the local session until the API registers them.
+	Session Session
+}
+
+// SessionListener observes the lifecycle of sessions. Listeners are called
+// synchronously from the goroutine that made the operation, which may be a
+// background goroutine of the session recorder, after the session state was
+// updated. Listeners must not block.
+type SessionListener interface {
+	OnStart(ctx context.Context, event SessionEvent)
+	OnStop(ctx context.Context, event SessionEvent)
+	OnSave(ctx context.Context, event SessionEvent)
+	OnCancel(ctx context.Context, event SessionEvent)
+	OnUpdate(ctx context.Context, event SessionEvent)
+	// OnError is called instead of the other callbacks when an operation fails
+	OnError(ctx context.Context, event SessionEvent, err error)
+	OnRemoteStateChange(ctx context.Context, change RemoteStateChange)
+}
+
+// NopSessionListener implements SessionListener with methods that do
+// nothing. Embed it to implement only some of the callbacks.
+type NopSessionListener struct{}
+
+func (NopSessionListener) OnStart(ctx context.Context, event SessionEvent)                   {}
+func (NopSessionListener) OnStop(ctx context.Context, event SessionEvent)                    {}
+func (NopSessionListener) OnSave(ctx context.Context, event SessionEvent)                    {}
+func (NopSessionListener) OnCancel(ctx context.Context, event SessionEvent)                  {}
+func (NopSessionListener) OnUpdate(ctx context.Context, event SessionEvent)                  {}
+func (NopSessionListener) OnError(ctx context.Context, event SessionEvent, err error)        {}
+func (NopSessionListener) OnRemoteStateChange(ctx context.Context, change RemoteStateChange) {}
+
+func (sr *SessionRecorder) getListeners() []SessionListener {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.listeners
+}
+
+// emit reports a finished operation to the listeners.
+// It must be called without sr.mutex held.
+func (sr *SessionRecorder) emit(ctx context.Context, event SessionEvent, err error) {
+	for _, listener := range sr.getListeners() {
+		if err != nil {
+			listener.OnError(ctx, event, err)
+			continue
+		}
+
+		switch event.Operation {
+		case SessionOperationStart:
+			listener.OnStart(ctx, event)
+		case SessionOperationStop:
+			listener.OnStop(ctx, event)
+		case SessionOperationSave:
+			listener.OnSave(ctx, event)
+		case SessionOperationCancel:
+			listener.OnCancel(ctx, event)
+		case SessionOperationUpdate:
+			listener.OnUpdate(ctx, event)
+		}
+	}
+}
+
+// emitRemoteStateChange reports a state change made by the remote control.
+// It must be called without sr.mutex held.
+func (sr *SessionRecorder) emitRemoteStateChange(ctx context.Context, change RemoteStateChange) {
+	for _, listener := range sr.getListeners() {
+		listener.OnRemoteStateChange(ctx, change)
+	}
+}
@@ -0,0 +1,110 @@
+package session_recorder
+
+import (
+	"context"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// sessionRegistration tracks the background registration of a session whose
+// short id was generated locally
+type sessionRegistration struct {
+	done chan struct{}
+	err  error
+}
+
+// startLocally starts tagging traces with the locally generated short id of
+// sessionPayload and registers the session with the API in the background.
+// The session must be in STARTING.
+func (sr *SessionRecorder) startLocally(ctx context.Context, trigger SessionTrigger, sessionType types.SessionType, sessionPayload Session) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if err := validateShortSessionID(sr.traceIDGenerator, sessionPayload.ShortID, sessionType); err != nil {
+		sr.transition(SessionStateStopped)
+		return err
+	}
+
+	registration := &sessionRegistration{
+		done: make(chan struct{}),
+	}
+	sr.registration = registration
+	sr.session = sessionPayload
+	sr.shortSessionID = sessionPayload.ShortID
+	sr.setTraceSessionId(sr.shortSessionID, sessionType)
+	if err := sr.transition(SessionStateStarted); err != nil {
+		return err
+	}
+
+	// the registration outlives the caller's context but keeps its values
+	go sr.register(context.WithoutCancel(ctx), trigger, sessionType, sessionPayload, registration)
+
+	return nil
+}
+
+// register starts a locally started session through the API. If the API
+// rejects the session it is stopped locally and the listeners are notified;
+// if the API assigns a different short id, that id is used from then on.
+func (sr *SessionRecorder) register(ctx context.Context, trigger SessionTrigger, sessionType types.SessionType, sessionPayload Session, registration *sessionRegistration) {
+	defer close(registration.done)
+
+	idempotencyKey := newIdempotencyKey()
+	session, err := sr.startSession(ctx, sessionType, sessionPayload, idempotencyKey)
+	if j := sr.getJournal(); j != nil && isOfflineError(ctx, err) {
+		// the session keeps recording and is registered by the journal replay
+		err = sr.enqueue(j, journalEntry{
+			Operation:      journalOperationStart,
+			SessionType:    sessionType,
+			ShortID:        sessionPayload.ShortID,
+			Session:        sessionPayload,
+			IdempotencyKey: idempotencyKey,
+		})
+		if err == nil {
+			return
+		}
+	}
+
+	if err == nil {
+		sr.replaceSession(sessionPayload.ShortID, *session)
+		return
+	}
+
+	sr.mutex.Lock()
+	if sr.registration != registration || sr.shortSessionID != sessionPayload.ShortID {
+		sr.mutex.Unlock()
+		return
+	}
+
+	registration.err = err
+	if sr.transition(SessionStateStopping) == nil {
+		sr.clearSession()
+		sr.transition(SessionStateStopped)
+	}
+	sr.mutex.Unlock()
+
+	sr.emit(ctx, SessionEvent{
+		Operation:   SessionOperationStart,
+		Trigger:     trigger,
+		SessionType: sessionType,
+		Session:     sessionPayload,
+	}, err)
+}
+
+// awaitRegistration blocks until the background registration of the current
+// session is done and returns its error
+func (sr *SessionRecorder) awaitRegistration(ctx context.Context) error {
+	sr.mutex.Lock()
+	registration := sr.registration
+	sr.mutex.Unlock()
+
+	if registration == nil {
+		return nil
+	}
+
+	select {
+	case <-registration.done:
+		return registration.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
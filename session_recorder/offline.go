@@ -0,0 +1,307 @@
+package session_recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// defaultOfflineReplayInterval is used when OfflineReplayInterval is not set
+const defaultOfflineReplayInterval = 30 * time.Second
+
+// isOfflineError reports whether err of a request made with ctx means that
+// the API could not be reached, as opposed to the API rejecting the request
+// or the caller giving up. Attempts that timed out count as unreachable.
+func isOfflineError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+func (sr *SessionRecorder) getJournal() *journal {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.journal
+}
+
+// callOrJournal runs call, or records entry in the offline journal instead
+// when the API cannot be reached or earlier operations are still waiting in
+// the journal, so operations reach the API in order. The request made by call
+// and its replay carry the same idempotency key.
+func (sr *SessionRecorder) callOrJournal(ctx context.Context, entry journalEntry, call func(ctx context.Context) error) error {
+	j := sr.getJournal()
+	if j == nil {
+		return call(ctx)
+	}
+
+	if j.pending() {
+		return sr.enqueue(j, entry)
+	}
+
+	entry.IdempotencyKey = newIdempotencyKey()
+	err := call(withIdempotencyKey(ctx, entry.IdempotencyKey))
+	if isOfflineError(ctx, err) {
+		return sr.enqueue(j, entry)
+	}
+	return err
+}
+
+// startOffline starts a session locally after the API could not be reached
+// and journals its start. The session must be in STARTING.
+func (sr *SessionRecorder) startOffline(j *journal, sessionType types.SessionType, sessionPayload Session, idempotencyKey string) error {
+	sr.mutex.Lock()
+	if err := validateShortSessionID(sr.traceIDGenerator, sessionPayload.ShortID, sessionType); err != nil {
+		sr.transition(SessionStateStopped)
+		sr.mutex.Unlock()
+		return err
+	}
+	sr.mutex.Unlock()
+
+	err := sr.enqueue(j, journalEntry{
+		Operation:      journalOperationStart,
+		SessionType:    sessionType,
+		ShortID:        sessionPayload.ShortID,
+		Session:        sessionPayload,
+		IdempotencyKey: idempotencyKey,
+	})
+
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if err != nil {
+		sr.transition(SessionStateStopped)
+		return err
+	}
+
+	sr.session = sessionPayload
+	sr.shortSessionID = sessionPayload.ShortID
+	sr.setTraceSessionId(sr.shortSessionID, sessionType)
+	return sr.transition(SessionStateStarted)
+}
+
+func (sr *SessionRecorder) enqueue(j *journal, entry journalEntry) error {
+	entry.CreatedAt = time.Now()
+	if entry.IdempotencyKey == "" {
+		entry.IdempotencyKey = newIdempotencyKey()
+	}
+	if err := j.append(entry); err != nil {
+		return err
+	}
+
+	sr.ensureJournalReplay()
+	return nil
+}
+
+// ReplayJournal sends the operations recorded while the API could not be
+// reached, in the order they were made. Replaying stops at the first
+// operation that still cannot reach the API. Operations rejected by the API
+// are dropped and returned as a joined error.
+func (sr *SessionRecorder) ReplayJournal(ctx context.Context) error {
+	j := sr.getJournal()
+	if j == nil {
+		return nil
+	}
+
+	sr.replayMutex.Lock()
+	defer sr.replayMutex.Unlock()
+
+	entries, err := j.entries()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	replayed := 0
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+
+		err := sr.replayEntry(ctx, j, entry, entries[i+1:])
+		if isOfflineError(ctx, err) || ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("dropped %s of session %s: %w", entry.Operation, entry.ShortID, err))
+		}
+		replayed++
+	}
+
+	if err := j.removeFirst(replayed); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// replayEntry sends a journaled operation to the API with the idempotency key
+// it was journaled with
+func (sr *SessionRecorder) replayEntry(ctx context.Context, j *journal, entry journalEntry, remaining []journalEntry) error {
+	if entry.Operation == journalOperationStart {
+		return sr.replayStart(ctx, j, entry, remaining)
+	}
+
+	ctx = withIdempotencyKey(ctx, entry.IdempotencyKey)
+	switch entry.Operation {
+	case journalOperationStop:
+		return sr.apiService.StopSessionContext(ctx, entry.ShortID, entry.Session)
+	case journalOperationSave:
+		return sr.apiService.SaveContinuousSessionContext(ctx, entry.ShortID, entry.Session)
+	case journalOperationUpdate:
+		if entry.SessionType == types.SESSION_TYPE_CONTINUOUS {
+			return sr.apiService.UpdateContinuousSessionContext(ctx, entry.ShortID, entry.Session)
+		}
+		return sr.apiService.UpdateSessionContext(ctx, entry.ShortID, entry.Session)
+	case journalOperationCancel:
+		if entry.SessionType == types.SESSION_TYPE_CONTINUOUS {
+			return sr.apiService.StopContinuousSessionContext(ctx, entry.ShortID)
+		}
+		return sr.apiService.CancelSessionContext(ctx, entry.ShortID)
+	}
+
+	return fmt.Errorf("unknown journal operation %q", entry.Operation)
+}
+
+// replayStart starts a journaled session. A start whose short id is already
+// used by the backend is retried with an id assigned by the backend, and the
+// remaining entries and the local session switch to it. Spans exported before
+// the replay keep the journaled short id, so they end up in the session that
+// already used it.
+func (sr *SessionRecorder) replayStart(ctx context.Context, j *journal, entry journalEntry, remaining []journalEntry) error {
+	session, err := sr.startSession(ctx, entry.SessionType, entry.Session, entry.IdempotencyKey)
+	if errors.Is(err, ErrConflict) {
+		sessionPayload := entry.Session
+		sessionPayload.ShortID = ""
+		// a different request than the journaled one, with a key of its own
+		// that is still the same on every replay
+		reassignKey := ""
+		if entry.IdempotencyKey != "" {
+			reassignKey = entry.IdempotencyKey + "-reassign"
+		}
+		session, err = sr.startSession(ctx, entry.SessionType, sessionPayload, reassignKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	if session.ShortID != entry.ShortID {
+		for i := range remaining {
+			if remaining[i].ShortID == entry.ShortID {
+				remaining[i].ShortID = session.ShortID
+				remaining[i].Session.ShortID = session.ShortID
+			}
+		}
+		if err := j.replaceShortID(entry.ShortID, session.ShortID); err != nil {
+			return err
+		}
+	}
+	sr.replaceSession(entry.ShortID, *session)
+	return nil
+}
+
+// replaceSession switches the active session started locally with the given
+// short id to the session registered by the API, which may have a different
+// short id
+func (sr *SessionRecorder) replaceSession(shortID string, session Session) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.shortSessionID != shortID {
+		return
+	}
+
+	sr.session = session
+	if session.ShortID == shortID {
+		return
+	}
+
+	sr.shortSessionID = session.ShortID
+	if sr.sessionState == SessionStateStarted {
+		sr.setTraceSessionId(sr.shortSessionID, sr.sessionType)
+	}
+}
+
+// ensureJournalReplay starts the background replay unless it is running or
+// was stopped by StopJournalReplay
+func (sr *SessionRecorder) ensureJournalReplay() {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sr.journal == nil || sr.journalReplayRunning || sr.journalReplayStopped {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	sr.journalReplayRunning = true
+	sr.journalReplayCancel = cancel
+	sr.journalReplayDone = done
+
+	go func(j *journal, interval time.Duration) {
+		defer close(done)
+		defer cancel()
+		sr.runJournalReplay(ctx, j, interval)
+	}(sr.journal, sr.offlineReplayInterval)
+}
+
+// runJournalReplay replays the journal every interval until it is empty or
+// ctx is done
+func (sr *SessionRecorder) runJournalReplay(ctx context.Context, j *journal, interval time.Duration) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+			sr.ReplayJournal(ctx)
+		}
+
+		sr.mutex.Lock()
+		if !j.pending() || ctx.Err() != nil {
+			sr.journalReplayRunning = false
+			sr.mutex.Unlock()
+			return
+		}
+		sr.mutex.Unlock()
+
+		timer.Reset(interval)
+	}
+}
+
+// StopJournalReplay stops the background replay of the offline journal, waits
+// for it to return and replays the journal one last time. Operations that
+// still cannot reach the API stay in the journal file for the next process;
+// operations journaled afterwards are only sent by ReplayJournal.
+func (sr *SessionRecorder) StopJournalReplay(ctx context.Context) error {
+	sr.mutex.Lock()
+	sr.journalReplayStopped = true
+	cancel, done := sr.journalReplayCancel, sr.journalReplayDone
+	sr.mutex.Unlock()
+
+	if cancel != nil {
+		cancel()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return sr.ReplayJournal(ctx)
+}
@@ -0,0 +1,93 @@
+package session_recorder_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+func newOfflineSessionRecorder(t *testing.T, api *sessionrecordertest.Server, replayInterval time.Duration, opts ...session_recorder.Option) *session_recorder.SessionRecorder {
+	t.Helper()
+
+	return newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(), append([]session_recorder.Option{
+		session_recorder.WithOfflineJournal(filepath.Join(t.TempDir(), "journal.jsonl"), replayInterval),
+		session_recorder.WithRetry(session_recorder.RetryConfig{MaxAttempts: 1}),
+	}, opts...)...)
+}
+
+func TestOfflineStartOnTimeout(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newOfflineSessionRecorder(t, api, time.Hour, session_recorder.WithTimeout(100*time.Millisecond))
+	api.SetLatency(time.Second)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertState(t, sr, session_recorder.SessionStateStarted)
+	shortSessionID := sr.ShortSessionID()
+
+	api.SetLatency(0)
+	if err := sr.ReplayJournal(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, shortSessionID, sessionrecordertest.SessionStateStarted)
+}
+
+func TestJournalReplayKeepsIdempotencyKey(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newOfflineSessionRecorder(t, api, 20*time.Millisecond)
+	api.Fail(sessionrecordertest.EndpointStartSession, http.StatusServiceUnavailable, 1)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, 2*time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointStartSession)) == 2
+	})
+
+	calls := api.CallsTo(sessionrecordertest.EndpointStartSession)
+	if calls[1].StatusCode != http.StatusOK {
+		t.Fatalf("expected the replayed start to succeed, got %d", calls[1].StatusCode)
+	}
+	first, replayed := calls[0].Header.Get("Idempotency-Key"), calls[1].Header.Get("Idempotency-Key")
+	if first == "" || first != replayed {
+		t.Errorf("expected the replay to reuse idempotency key %q, got %q", first, replayed)
+	}
+}
+
+func TestStopJournalReplay(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newOfflineSessionRecorder(t, api, 10*time.Millisecond)
+	api.Fail(sessionrecordertest.EndpointStartSession, http.StatusServiceUnavailable, -1)
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, 2*time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointStartSession)) >= 2
+	})
+
+	if err := sr.StopJournalReplay(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := len(api.CallsTo(sessionrecordertest.EndpointStartSession))
+	time.Sleep(50 * time.Millisecond)
+	if after := len(api.CallsTo(sessionrecordertest.EndpointStartSession)); after != calls {
+		t.Errorf("expected the replay to stop, got %d more calls", after-calls)
+	}
+}
@@ -0,0 +1,273 @@
+package session_recorder
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+)
+
+// Option configures a session recorder created with New
+type Option func(*SessionRecorderConfig)
+
+func WithAPIKey(apiKey string) Option {
+	return func(c *SessionRecorderConfig) {
+		c.APIKey = apiKey
+	}
+}
+
+func WithAPIBaseURL(apiBaseURL string) Option {
+	return func(c *SessionRecorderConfig) {
+		c.APIBaseURL = apiBaseURL
+	}
+}
+
+func WithTraceIDGenerator(traceIDGenerator TraceIDGenerator) Option {
+	return func(c *SessionRecorderConfig) {
+		c.TraceIDGenerator = traceIDGenerator
+	}
+}
+
+func WithResourceAttributes(resourceAttributes map[string]interface{}) Option {
+	return func(c *SessionRecorderConfig) {
+		c.ResourceAttributes = resourceAttributes
+	}
+}
+
+// WithGenerateSessionShortIDLocally generates short session ids with the
+// default generator
+func WithGenerateSessionShortIDLocally(generate bool) Option {
+	return func(c *SessionRecorderConfig) {
+		c.GenerateSessionShortIDLocally = generate
+	}
+}
+
+// WithSessionShortIDGenerator generates short session ids locally with
+// generator, which must return 16 character ids
+func WithSessionShortIDGenerator(generator func() string) Option {
+	return func(c *SessionRecorderConfig) {
+		c.GenerateSessionShortIDLocally = generator
+	}
+}
+
+func WithReportPauseResume(report bool) Option {
+	return func(c *SessionRecorderConfig) {
+		c.ReportPauseResume = report
+	}
+}
+
+func WithContextScoped(contextScoped bool) Option {
+	return func(c *SessionRecorderConfig) {
+		c.ContextScoped = contextScoped
+	}
+}
+
+func WithOfflineJournal(path string, replayInterval time.Duration) Option {
+	return func(c *SessionRecorderConfig) {
+		c.OfflineJournalPath = path
+		c.OfflineReplayInterval = replayInterval
+	}
+}
+
+func WithRetry(retry RetryConfig) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Retry = retry
+	}
+}
+
+func WithMaxSessionDuration(maxSessionDuration time.Duration) Option {
+	return func(c *SessionRecorderConfig) {
+		c.MaxSessionDuration = maxSessionDuration
+	}
+}
+
+func WithIdleTimeout(idleTimeout time.Duration) Option {
+	return func(c *SessionRecorderConfig) {
+		c.IdleTimeout = idleTimeout
+	}
+}
+
+// WithHTTPClient sends API requests with client
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *SessionRecorderConfig) {
+		c.HTTPClient = client
+	}
+}
+
+// WithTransport sends API requests through transport, e.g. to use a proxy or
+// a custom CA
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Transport = transport
+	}
+}
+
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Timeout = timeout
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *SessionRecorderConfig) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithHeaders adds headers to every API request
+func WithHeaders(headers map[string]string) Option {
+	return func(c *SessionRecorderConfig) {
+		if c.Headers == nil {
+			c.Headers = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			c.Headers[k] = v
+		}
+	}
+}
+
+func WithProviders(providers ...Provider) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Providers = append(c.Providers, providers...)
+	}
+}
+
+func WithFlushers(flushers ...Flusher) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Flushers = append(c.Flushers, flushers...)
+	}
+}
+
+func WithListeners(listeners ...SessionListener) Option {
+	return func(c *SessionRecorderConfig) {
+		c.Listeners = append(c.Listeners, listeners...)
+	}
+}
+
+// ConfigFromEnv reads the session recorder config from the environment:
+//
+//	MULTIPLAYER_API_KEY
+//	MULTIPLAYER_API_BASE_URL
+//	MULTIPLAYER_GENERATE_SESSION_SHORT_ID_LOCALLY (bool)
+//	MULTIPLAYER_REPORT_PAUSE_RESUME (bool)
+//	MULTIPLAYER_OFFLINE_JOURNAL_PATH
+//	MULTIPLAYER_MAX_SESSION_DURATION (duration, e.g. "1h")
+//	MULTIPLAYER_IDLE_TIMEOUT (duration)
+//
+// Unset variables are left empty. Variables that cannot be parsed are
+// reported together as ConfigErrors.
+func ConfigFromEnv() (SessionRecorderConfig, error) {
+	var errs []error
+	config := SessionRecorderConfig{
+		APIKey:             os.Getenv(constants.ENV_MULTIPLAYER_API_KEY),
+		APIBaseURL:         os.Getenv(constants.ENV_MULTIPLAYER_API_BASE_URL),
+		OfflineJournalPath: os.Getenv(constants.ENV_MULTIPLAYER_OFFLINE_JOURNAL_PATH),
+	}
+
+	if generate, ok, err := boolFromEnv(constants.ENV_MULTIPLAYER_GENERATE_SESSION_SHORT_ID_LOCALLY); err != nil {
+		errs = append(errs, err)
+	} else if ok {
+		config.GenerateSessionShortIDLocally = generate
+	}
+
+	if report, _, err := boolFromEnv(constants.ENV_MULTIPLAYER_REPORT_PAUSE_RESUME); err != nil {
+		errs = append(errs, err)
+	} else {
+		config.ReportPauseResume = report
+	}
+
+	var err error
+	if config.MaxSessionDuration, err = durationFromEnv(constants.ENV_MULTIPLAYER_MAX_SESSION_DURATION); err != nil {
+		errs = append(errs, err)
+	}
+	if config.IdleTimeout, err = durationFromEnv(constants.ENV_MULTIPLAYER_IDLE_TIMEOUT); err != nil {
+		errs = append(errs, err)
+	}
+
+	return config, errors.Join(errs...)
+}
+
+func boolFromEnv(name string) (bool, bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return false, false, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false, &ConfigError{Field: name, Err: err}
+	}
+	return b, true, nil
+}
+
+func durationFromEnv(name string) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, &ConfigError{Field: name, Err: err}
+	}
+	return d, nil
+}
+
+var (
+	errNegative       = errors.New("should not be negative")
+	errInvalidBaseURL = errors.New("should be an absolute http or https URL")
+)
+
+// validate returns a ConfigError for every misconfigured field
+func (c SessionRecorderConfig) validate() error {
+	var errs []error
+	invalid := func(field string, err error) {
+		errs = append(errs, &ConfigError{Field: field, Err: err})
+	}
+
+	if c.APIKey == "" {
+		invalid("APIKey", ErrAPIKeyNotProvided)
+	}
+	if c.TraceIDGenerator == nil {
+		invalid("TraceIDGenerator", ErrIncompatibleTraceIDGenerator)
+	}
+
+	switch c.GenerateSessionShortIDLocally.(type) {
+	case nil, bool, func() string:
+	default:
+		invalid("GenerateSessionShortIDLocally", errors.New("should be a bool or a func() string"))
+	}
+
+	if c.APIBaseURL != "" {
+		u, err := url.Parse(c.APIBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("APIBaseURL", errInvalidBaseURL)
+		}
+	}
+
+	durations := []struct {
+		field string
+		value time.Duration
+	}{
+		{"OfflineReplayInterval", c.OfflineReplayInterval},
+		{"MaxSessionDuration", c.MaxSessionDuration},
+		{"IdleTimeout", c.IdleTimeout},
+		{"Timeout", c.Timeout},
+		{"Retry.InitialBackoff", c.Retry.InitialBackoff},
+		{"Retry.MaxBackoff", c.Retry.MaxBackoff},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			invalid(d.field, errNegative)
+		}
+	}
+	if c.Retry.MaxAttempts < 0 {
+		invalid("Retry.MaxAttempts", errNegative)
+	}
+
+	return errors.Join(errs...)
+}
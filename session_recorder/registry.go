@@ -0,0 +1,192 @@
+package session_recorder
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// MultiSessionTraceIDGenerator is implemented by trace id generators that can
+// tag traces for several sessions at once
+type MultiSessionTraceIDGenerator interface {
+	TraceIDGenerator
+	AddSession(sessionShortId string, sessionType types.SessionType, match func(ctx context.Context) bool)
+	RemoveSession(sessionShortId string)
+}
+
+// SessionMatcher reports whether a trace started from ctx belongs to a
+// session, e.g. by comparing a tenant id stored in the context. A nil
+// SessionMatcher matches every trace.
+type SessionMatcher func(ctx context.Context) bool
+
+// SessionRegistry records many sessions at the same time. Every session has
+// its own SessionRecorder with its own type, attributes and lifecycle, and
+// the shared trace id generator picks the session for each new trace with
+// the session's SessionMatcher.
+type SessionRegistry struct {
+	mutex            sync.Mutex
+	isInitialized    bool
+	config           SessionRecorderConfig
+	traceIDGenerator MultiSessionTraceIDGenerator
+	sessions         map[string]*SessionRecorder
+}
+
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{
+		sessions: make(map[string]*SessionRecorder),
+	}
+}
+
+// errRegistryOfflineJournal is returned by SessionRegistry.Init for configs
+// with an OfflineJournalPath. Every session would open its own journal on the
+// same file and rewrite the entries of the others.
+var errRegistryOfflineJournal = errors.New("offline mode is not supported by SessionRegistry")
+
+// Init configures the registry. The config is shared by all sessions, its
+// TraceIDGenerator must implement MultiSessionTraceIDGenerator and it cannot
+// enable offline mode.
+func (r *SessionRegistry) Init(config SessionRecorderConfig) error {
+	if config.APIKey == "" {
+		return ErrAPIKeyNotProvided
+	}
+	if config.OfflineJournalPath != "" {
+		return &ConfigError{Field: "OfflineJournalPath", Err: errRegistryOfflineJournal}
+	}
+
+	traceIDGenerator, ok := config.TraceIDGenerator.(MultiSessionTraceIDGenerator)
+	if !ok {
+		return ErrIncompatibleTraceIDGenerator
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.config = config
+	r.traceIDGenerator = traceIDGenerator
+	r.isInitialized = true
+	return nil
+}
+
+// Start starts a new session and adds it to the registry. The returned
+// SessionRecorder controls the session; stopped sessions are removed from the
+// registry automatically.
+func (r *SessionRegistry) Start(ctx context.Context, sessionType types.SessionType, sessionPayload *Session, match SessionMatcher) (*SessionRecorder, error) {
+	r.mutex.Lock()
+	if !r.isInitialized {
+		r.mutex.Unlock()
+		return nil, ErrNotInitialized
+	}
+	config := r.config
+	config.TraceIDGenerator = &registryTraceIDGenerator{
+		traceIDGenerator: r.traceIDGenerator,
+		match:            match,
+	}
+	r.mutex.Unlock()
+
+	sr := NewSessionRecorder()
+	if err := sr.Init(config); err != nil {
+		return nil, err
+	}
+
+	if err := sr.StartContext(ctx, sessionType, sessionPayload); err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.sessions[sr.ShortSessionID()] = sr
+
+	return sr, nil
+}
+
+// Get returns the active session with the given short id
+func (r *SessionRegistry) Get(shortSessionID string) (*SessionRecorder, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.prune()
+	sr, ok := r.sessions[shortSessionID]
+	return sr, ok
+}
+
+// Sessions returns all active sessions
+func (r *SessionRegistry) Sessions() []*SessionRecorder {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.prune()
+	sessions := make([]*SessionRecorder, 0, len(r.sessions))
+	for _, sr := range r.sessions {
+		sessions = append(sessions, sr)
+	}
+	return sessions
+}
+
+// Stop stops the session with the given short id
+func (r *SessionRegistry) Stop(ctx context.Context, shortSessionID string, sessionData *Session) error {
+	sr, ok := r.Get(shortSessionID)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	defer r.removeStopped()
+
+	return sr.StopContext(ctx, sessionData)
+}
+
+// Cancel cancels the session with the given short id
+func (r *SessionRegistry) Cancel(ctx context.Context, shortSessionID string) error {
+	sr, ok := r.Get(shortSessionID)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	defer r.removeStopped()
+
+	return sr.CancelContext(ctx)
+}
+
+func (r *SessionRegistry) removeStopped() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.prune()
+}
+
+// prune drops stopped sessions and re-keys sessions whose short id was
+// replaced by the API after a local start. It must be called with r.mutex held.
+func (r *SessionRegistry) prune() {
+	sessions := make(map[string]*SessionRecorder, len(r.sessions))
+	for _, sr := range r.sessions {
+		if shortSessionID := sr.ShortSessionID(); shortSessionID != "" && sr.State() != SessionStateStopped {
+			sessions[shortSessionID] = sr
+		}
+	}
+	r.sessions = sessions
+}
+
+// registryTraceIDGenerator adapts the shared MultiSessionTraceIDGenerator to
+// the single session interface used by SessionRecorder
+type registryTraceIDGenerator struct {
+	traceIDGenerator MultiSessionTraceIDGenerator
+	match            SessionMatcher
+	sessionShortId   string
+}
+
+func (g *registryTraceIDGenerator) SetSessionId(sessionShortId string, sessionType types.SessionType) {
+	if g.sessionShortId != "" {
+		g.traceIDGenerator.RemoveSession(g.sessionShortId)
+	}
+
+	g.sessionShortId = sessionShortId
+	if sessionShortId != "" {
+		g.traceIDGenerator.AddSession(sessionShortId, sessionType, g.match)
+	}
+}
+
+func (g *registryTraceIDGenerator) ValidateSessionId(sessionShortId string, sessionType types.SessionType) error {
+	if validator, ok := g.traceIDGenerator.(sessionIDValidator); ok {
+		return validator.ValidateSessionId(sessionShortId, sessionType)
+	}
+	return nil
+}
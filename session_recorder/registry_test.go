@@ -0,0 +1,159 @@
+package session_recorder_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+type tenantKey struct{}
+
+func tenantMatcher(tenant string) session_recorder.SessionMatcher {
+	return func(ctx context.Context) bool {
+		return ctx.Value(tenantKey{}) == tenant
+	}
+}
+
+func newSessionRegistry(t *testing.T, api *sessionrecordertest.Server, idGenerator *multiplayer.SessionRecorderIdGenerator) *session_recorder.SessionRegistry {
+	t.Helper()
+
+	config := session_recorder.SessionRecorderConfig{TraceIDGenerator: idGenerator}
+	for _, opt := range api.Options() {
+		opt(&config)
+	}
+	registry := session_recorder.NewSessionRegistry()
+	if err := registry.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
+// tracedSession returns the short id of the session a trace started from ctx
+// is tagged with
+func tracedSession(idGenerator *multiplayer.SessionRecorderIdGenerator, ctx context.Context) string {
+	traceID, _ := idGenerator.NewIDs(ctx)
+	_, shortSessionID, _ := multiplayer.Decode(traceID)
+	return shortSessionID
+}
+
+func TestSessionRegistry(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	registry := newSessionRegistry(t, api, idGenerator)
+	ctx := context.Background()
+	acmeCtx := context.WithValue(ctx, tenantKey{}, "acme")
+	globexCtx := context.WithValue(ctx, tenantKey{}, "globex")
+
+	acme, err := registry.Start(ctx, types.SESSION_TYPE_MANUAL, nil, tenantMatcher("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	globex, err := registry.Start(ctx, types.SESSION_TYPE_CONTINUOUS, nil, tenantMatcher("globex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sessions := registry.Sessions(); len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	if sr, ok := registry.Get(acme.ShortSessionID()); !ok || sr != acme {
+		t.Errorf("expected Get to return the acme session")
+	}
+
+	if got := tracedSession(idGenerator, acmeCtx); got != acme.ShortSessionID() {
+		t.Errorf("expected acme traces tagged with %s, got %q", acme.ShortSessionID(), got)
+	}
+	if got := tracedSession(idGenerator, globexCtx); got != globex.ShortSessionID() {
+		t.Errorf("expected globex traces tagged with %s, got %q", globex.ShortSessionID(), got)
+	}
+	if got := tracedSession(idGenerator, ctx); got != "" {
+		t.Errorf("expected other traces untagged, got session %s", got)
+	}
+
+	acmeID := acme.ShortSessionID()
+	if err := registry.Stop(ctx, acmeID, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, acmeID, sessionrecordertest.SessionStateStopped)
+	if _, ok := registry.Get(acmeID); ok {
+		t.Error("expected the stopped session to be removed")
+	}
+	if got := tracedSession(idGenerator, acmeCtx); got != "" {
+		t.Errorf("expected acme traces untagged once stopped, got session %s", got)
+	}
+	if got := tracedSession(idGenerator, globexCtx); got != globex.ShortSessionID() {
+		t.Errorf("expected globex traces still tagged with %s, got %q", globex.ShortSessionID(), got)
+	}
+
+	// sessions stopped through their recorder are removed too
+	globexID := globex.ShortSessionID()
+	if err := globex.CancelContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertServerSession(t, api, globexID, sessionrecordertest.SessionStateCanceled)
+	if sessions := registry.Sessions(); len(sessions) != 0 {
+		t.Errorf("expected no session left, got %d", len(sessions))
+	}
+	if err := registry.Stop(ctx, globexID, nil); !errors.Is(err, session_recorder.ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestSessionRegistryNilMatcher(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	registry := newSessionRegistry(t, api, idGenerator)
+
+	sr, err := registry.Start(context.Background(), types.SESSION_TYPE_MANUAL, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tracedSession(idGenerator, context.Background()); got != sr.ShortSessionID() {
+		t.Errorf("expected every trace tagged with %s, got %q", sr.ShortSessionID(), got)
+	}
+}
+
+func TestSessionRegistryInit(t *testing.T) {
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+
+	tests := []struct {
+		name   string
+		config session_recorder.SessionRecorderConfig
+		err    error
+	}{
+		{"api key", session_recorder.SessionRecorderConfig{TraceIDGenerator: idGenerator}, session_recorder.ErrAPIKeyNotProvided},
+		{"trace id generator", session_recorder.SessionRecorderConfig{APIKey: "api-key"}, session_recorder.ErrIncompatibleTraceIDGenerator},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := session_recorder.NewSessionRegistry().Init(tt.config); !errors.Is(err, tt.err) {
+				t.Errorf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+
+	t.Run("offline journal", func(t *testing.T) {
+		err := session_recorder.NewSessionRegistry().Init(session_recorder.SessionRecorderConfig{
+			APIKey:             "api-key",
+			TraceIDGenerator:   idGenerator,
+			OfflineJournalPath: "journal.jsonl",
+		})
+		var configErr *session_recorder.ConfigError
+		if !errors.As(err, &configErr) || configErr.Field != "OfflineJournalPath" {
+			t.Errorf("expected a ConfigError for OfflineJournalPath, got %v", err)
+		}
+	})
+
+	if _, err := session_recorder.NewSessionRegistry().Start(context.Background(), types.SESSION_TYPE_MANUAL, nil, nil); !errors.Is(err, session_recorder.ErrNotInitialized) {
+		t.Errorf("expected ErrNotInitialized, got %v", err)
+	}
+}
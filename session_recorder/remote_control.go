@@ -0,0 +1,107 @@
+package session_recorder
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	// maxRemoteControlBackoff caps the delay between failing remote checks
+	maxRemoteControlBackoff = 5 * time.Minute
+
+	// remoteControlJitter is the fraction by which every delay is randomized
+	remoteControlJitter = 0.1
+)
+
+// RemoteStateChange describes a change of the local session state made by
+// the remote control poller
+type RemoteStateChange struct {
+	PreviousState SessionState
+	State         SessionState
+}
+
+// StartRemoteControl polls CheckRemoteContinuousSession in the background,
+// so continuous sessions can be started and stopped from the Multiplayer
+// dashboard. Checks run every interval with jitter, failed checks are retried
+// with exponential backoff. onChange, if not nil, is called from the polling
+// goroutine whenever a check changes the session state. The poller stops
+// when ctx is done.
+func (sr *SessionRecorder) StartRemoteControl(ctx context.Context, interval time.Duration, onChange func(RemoteStateChange)) error {
+	if interval <= 0 {
+		return ErrInvalidInterval
+	}
+
+	sr.mutex.Lock()
+	if !sr.isInitialized {
+		sr.mutex.Unlock()
+		return ErrNotInitialized
+	}
+	if sr.remoteControlRunning {
+		sr.mutex.Unlock()
+		return ErrRemoteControlRunning
+	}
+	sr.remoteControlRunning = true
+	sr.mutex.Unlock()
+
+	go sr.runRemoteControl(ctx, interval, onChange)
+
+	return nil
+}
+
+func (sr *SessionRecorder) runRemoteControl(ctx context.Context, interval time.Duration, onChange func(RemoteStateChange)) {
+	defer func() {
+		sr.mutex.Lock()
+		sr.remoteControlRunning = false
+		sr.mutex.Unlock()
+	}()
+
+	// the first check runs right away
+	delay := time.Duration(0)
+	failures := 0
+
+	for {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		previousState := sr.State()
+		err := sr.CheckRemoteContinuousSessionContext(ctx, nil)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+			if state := sr.State(); state != previousState && onChange != nil {
+				onChange(RemoteStateChange{
+					PreviousState: previousState,
+					State:         state,
+				})
+			}
+		}
+
+		delay = remoteControlDelay(interval, failures)
+	}
+}
+
+// remoteControlDelay returns the jittered delay before the next check. Every
+// consecutive failure doubles the delay up to maxRemoteControlBackoff.
+func remoteControlDelay(interval time.Duration, failures int) time.Duration {
+	delay := interval
+	for i := 0; i < failures && delay < maxRemoteControlBackoff; i++ {
+		delay *= 2
+	}
+	if failures > 0 && delay > maxRemoteControlBackoff {
+		delay = max(maxRemoteControlBackoff, interval)
+	}
+
+	jitter := 1 + remoteControlJitter*(2*rand.Float64()-1)
+	return time.Duration(float64(delay) * jitter)
+}
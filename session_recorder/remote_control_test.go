@@ -0,0 +1,178 @@
+package session_recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+func TestRemoteStopKeepsManualSession(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+	api.SetRemoteState("STOP")
+
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := sr.StartRemoteControl(ctx, 10*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession)) >= 5
+	})
+	assertState(t, sr, session_recorder.SessionStateStarted)
+	if calls := api.CallsTo(sessionrecordertest.EndpointStopSession); len(calls) != 0 {
+		t.Errorf("expected the manual session not to be stopped, got %d stops", len(calls))
+	}
+}
+
+func TestRemoteControlStart(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+	api.SetRemoteState("START")
+
+	var mutex sync.Mutex
+	var changes []session_recorder.RemoteStateChange
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := sr.StartRemoteControl(ctx, 10*time.Millisecond, func(change session_recorder.RemoteStateChange) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		changes = append(changes, change)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession)) >= 3
+	})
+	assertState(t, sr, session_recorder.SessionStateStarted)
+	if sr.SessionType() != types.SESSION_TYPE_CONTINUOUS {
+		t.Errorf("expected a continuous session, got %d", sr.SessionType())
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	want := session_recorder.RemoteStateChange{PreviousState: session_recorder.SessionStateStopped, State: session_recorder.SessionStateStarted}
+	if len(changes) != 1 || changes[0] != want {
+		t.Errorf("expected a single change %+v, got %+v", want, changes)
+	}
+}
+
+func TestRemoteControlBackoff(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(),
+		session_recorder.WithRetry(session_recorder.RetryConfig{MaxAttempts: 1}),
+	)
+	api.Fail(sessionrecordertest.EndpointCheckRemoteSession, http.StatusInternalServerError, -1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interval := 10 * time.Millisecond
+	if err := sr.StartRemoteControl(ctx, interval, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// checks run at about 0, 10, 30, 70, 150 and 310ms while failing, and
+	// every 10ms once the API recovers
+	time.Sleep(300 * time.Millisecond)
+	failed := len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession))
+	if failed < 2 || failed > 6 {
+		t.Errorf("expected failed checks to back off, got %d checks in 300ms", failed)
+	}
+
+	// the pending delay of up to 176ms runs out, then the interval is back
+	api.Reset()
+	waitFor(t, time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession)) >= 20
+	})
+}
+
+func TestRemoteControlDelay(t *testing.T) {
+	interval := time.Second
+
+	for failures, backoff := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		low, high := backoff*9/10, backoff*11/10
+		seen := map[time.Duration]bool{}
+		for i := 0; i < 100; i++ {
+			delay := session_recorder.RemoteControlDelay(interval, failures)
+			if delay < low || delay > high {
+				t.Fatalf("expected the delay after %d failures within [%s, %s], got %s", failures, low, high, delay)
+			}
+			seen[delay] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("expected jittered delays after %d failures, got %v", failures, seen)
+		}
+	}
+
+	capped := session_recorder.MaxRemoteControlBackoff
+	for _, delay := range []time.Duration{
+		session_recorder.RemoteControlDelay(interval, 20),
+		session_recorder.RemoteControlDelay(interval, 1000),
+	} {
+		if delay < capped*9/10 || delay > capped*11/10 {
+			t.Errorf("expected the delay capped at %s, got %s", capped, delay)
+		}
+	}
+
+	// intervals above the cap are kept
+	interval = 2 * capped
+	if delay := session_recorder.RemoteControlDelay(interval, 3); delay < interval*9/10 || delay > interval*11/10 {
+		t.Errorf("expected the interval %s to be kept, got %s", interval, delay)
+	}
+}
+
+func TestRemoteControlStops(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+
+	if err := sr.StartRemoteControl(context.Background(), 0, nil); !errors.Is(err, session_recorder.ErrInvalidInterval) {
+		t.Errorf("expected ErrInvalidInterval, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := sr.StartRemoteControl(ctx, 10*time.Millisecond, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sr.StartRemoteControl(ctx, 10*time.Millisecond, nil); !errors.Is(err, session_recorder.ErrRemoteControlRunning) {
+		t.Errorf("expected ErrRemoteControlRunning, got %v", err)
+	}
+	waitFor(t, time.Second, func() bool {
+		return len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession)) >= 2
+	})
+
+	cancel()
+	// the poller is done once it can be started again
+	waitFor(t, time.Second, func() bool {
+		restartCtx, restartCancel := context.WithCancel(context.Background())
+		restartCancel()
+		return sr.StartRemoteControl(restartCtx, time.Hour, nil) == nil
+	})
+	checks := len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession))
+	time.Sleep(50 * time.Millisecond)
+	if n := len(api.CallsTo(sessionrecordertest.EndpointCheckRemoteSession)); n != checks {
+		t.Errorf("expected no check once ctx is done, got %d more", n-checks)
+	}
+}
@@ -0,0 +1,149 @@
+package session_recorder
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryInitialBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff     = 10 * time.Second
+)
+
+// RetryConfig configures how failed API requests are retried. Network errors
+// and 429, 500, 502, 503 and 504 responses are retried with exponential
+// backoff and jitter; a Retry-After header on 429 and 503 responses takes
+// precedence over the backoff, up to MaxBackoff. Zero fields use the
+// defaults.
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per request, 1 disables retries
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// OnRetry is called before every retry
+	OnRetry func(RetryAttempt)
+}
+
+// RetryAttempt describes a failed attempt that is about to be retried
+type RetryAttempt struct {
+	Method string
+	Path   string
+	// Attempt is the number of the failed attempt, starting at 1
+	Attempt int
+	// Delay is the time to wait before the next attempt
+	Delay time.Duration
+	Err   error
+}
+
+func (c RetryConfig) isZero() bool {
+	return c.MaxAttempts == 0 && c.InitialBackoff == 0 && c.MaxBackoff == 0 && c.OnRetry == nil
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = defaultRetryMaxAttempts
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultRetryInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultRetryMaxBackoff
+	}
+	return c
+}
+
+// backoff returns the jittered delay after the given failed attempt
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	delay := c.InitialBackoff
+	for i := 1; i < attempt && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > c.MaxBackoff {
+		delay = c.MaxBackoff
+	}
+
+	// equal jitter: half of the delay is fixed, the other half is random
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isRetryableError reports whether a failed request made with ctx may succeed
+// when retried. Attempts that timed out are retried, unless ctx is done.
+func isRetryableError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+// newIdempotencyKey returns a random key sent with every attempt of a request,
+// so the API can recognize retries of a request it already handled
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	crand.Read(key)
+	return hex.EncodeToString(key)
+}
+
+type idempotencyKeyContextKey struct{}
+
+// withIdempotencyKey makes the request made with ctx carry key instead of a
+// new one, so a journaled request is replayed with the key it was first sent
+// with. An empty key is ignored.
+func withIdempotencyKey(ctx context.Context, key string) context.Context {
+	if key == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, idempotencyKeyContextKey{}, key)
+}
+
+func idempotencyKeyFromContext(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyContextKey{}).(string); ok {
+		return key
+	}
+	return newIdempotencyKey()
+}
@@ -0,0 +1,146 @@
+package session_recorder_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// newScriptedAPI returns an API whose session start fails as long as fail
+// returns true for the attempt, and succeeds afterwards
+func newScriptedAPI(t *testing.T, fail func(w http.ResponseWriter, r *http.Request, attempt int) bool) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var attempts atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the request context is only canceled once the body was read
+		io.Copy(io.Discard, r.Body)
+		if fail(w, r, int(attempts.Add(1))) {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session_recorder.Session{ID: "1", ShortID: "0123456789abcdef"})
+	}))
+	t.Cleanup(api.Close)
+	return api, &attempts
+}
+
+func newScriptedSessionRecorder(t *testing.T, apiURL string, opts ...session_recorder.Option) *session_recorder.SessionRecorder {
+	t.Helper()
+
+	opts = append([]session_recorder.Option{
+		session_recorder.WithAPIKey("api-key"),
+		session_recorder.WithAPIBaseURL(apiURL),
+		session_recorder.WithTraceIDGenerator(multiplayer.NewSessionRecorderIdGenerator()),
+	}, opts...)
+	sr, err := session_recorder.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sr
+}
+
+func TestRetryAttemptTimeout(t *testing.T) {
+	// the first attempt hangs until the client gives up on it
+	api, attempts := newScriptedAPI(t, func(w http.ResponseWriter, r *http.Request, attempt int) bool {
+		if attempt == 1 {
+			<-r.Context().Done()
+			return true
+		}
+		return false
+	})
+
+	sr := newScriptedSessionRecorder(t, api.URL,
+		session_recorder.WithTimeout(50*time.Millisecond),
+		session_recorder.WithRetry(fastRetry),
+	)
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("expected the timed out attempt to be retried once, got %d attempts", n)
+	}
+}
+
+func TestRetryCallerDeadline(t *testing.T) {
+	api, attempts := newScriptedAPI(t, func(w http.ResponseWriter, r *http.Request, attempt int) bool {
+		<-r.Context().Done()
+		return true
+	})
+
+	sr := newScriptedSessionRecorder(t, api.URL, session_recorder.WithRetry(fastRetry))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err == nil {
+		t.Fatal("expected the start to fail")
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("expected no retry once the caller gave up, got %d attempts", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	api, attempts := newScriptedAPI(t, func(w http.ResponseWriter, r *http.Request, attempt int) bool {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "86400")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+
+	var delays []time.Duration
+	retry := fastRetry
+	retry.MaxBackoff = 20 * time.Millisecond
+	retry.OnRetry = func(attempt session_recorder.RetryAttempt) {
+		delays = append(delays, attempt.Delay)
+	}
+
+	sr := newScriptedSessionRecorder(t, api.URL, session_recorder.WithRetry(retry))
+	start := time.Now()
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Retry-After to be capped, the start took %s", elapsed)
+	}
+	if len(delays) != 1 || delays[0] != retry.MaxBackoff {
+		t.Errorf("expected a single retry after %s, got %v", retry.MaxBackoff, delays)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Errorf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestRetryAfterPastDeadline(t *testing.T) {
+	api, attempts := newScriptedAPI(t, func(w http.ResponseWriter, r *http.Request, attempt int) bool {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return true
+	})
+
+	retry := fastRetry
+	retry.MaxBackoff = time.Minute
+	sr := newScriptedSessionRecorder(t, api.URL, session_recorder.WithRetry(retry))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil)
+	var apiErr *session_recorder.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the 429 to be returned right away, got %v", err)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("expected a single attempt, got %d", n)
+	}
+}
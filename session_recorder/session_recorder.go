@@ -1,8 +1,13 @@
 package session_recorder
 
 import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
@@ -12,9 +17,11 @@ import (
 type SessionState string
 
 const (
-	SessionStateStarted SessionState = "STARTED"
-	SessionStateStopped SessionState = "STOPPED"
-	SessionStatePaused  SessionState = "PAUSED"
+	SessionStateStarting SessionState = "STARTING"
+	SessionStateStarted  SessionState = "STARTED"
+	SessionStateStopping SessionState = "STOPPING"
+	SessionStateStopped  SessionState = "STOPPED"
+	SessionStatePaused   SessionState = "PAUSED"
 )
 
 type SessionRecorderConfig struct {
@@ -23,23 +30,102 @@ type SessionRecorderConfig struct {
 	ResourceAttributes            map[string]interface{}
 	GenerateSessionShortIDLocally interface{}
 	APIBaseURL                    string
+	// ReportPauseResume reports Pause and Resume to the Multiplayer API.
+	// Enable it only when the backend supports pausing sessions.
+	ReportPauseResume bool
+	// ContextScoped sessions only tag traces started from a context bound
+	// with WithSession instead of every new trace in the process.
+	ContextScoped bool
+	// OfflineJournalPath enables offline mode. Sessions are started locally
+	// when the API cannot be reached and session operations are stored in
+	// this file until they can be replayed.
+	OfflineJournalPath string
+	// OfflineReplayInterval is the delay between replays of the offline
+	// journal, 30 seconds by default
+	OfflineReplayInterval time.Duration
+	// Retry configures retries of failed API requests
+	Retry RetryConfig
+	// MaxSessionDuration stops sessions that run longer, 0 means no limit
+	MaxSessionDuration time.Duration
+	// IdleTimeout stops sessions in which no spans were started for this
+	// long, 0 means no limit. It requires an IdleSpanProcessor.
+	IdleTimeout time.Duration
+	// HTTPClient sends the requests to the Multiplayer API. Transport and
+	// Timeout are ignored when it is set.
+	HTTPClient *http.Client
+	// Transport is used by the default HTTP client, e.g. for proxies or
+	// custom CAs
+	Transport http.RoundTripper
+	// Timeout limits every API request attempt of the default HTTP client,
+	// 30 seconds by default
+	Timeout time.Duration
+	// UserAgent is put in front of the User-Agent of the SDK
+	UserAgent string
+	// Headers are added to every API request
+	Headers map[string]string
+	// Providers are force flushed before every save of a continuous session,
+	// so spans and log records queued in their batch processors reach the
+	// Flushers
+	Providers []Provider
+	// Flushers are flushed before every save of a continuous session, e.g.
+	// the flight recorder exporters of the exporters package
+	Flushers []Flusher
+	// Listeners are notified when sessions start, stop, are saved,
+	// canceled or updated, and when an operation fails
+	Listeners []SessionListener
+}
+
+// Flusher sends buffered telemetry
+type Flusher interface {
+	Flush(ctx context.Context) error
 }
 
 type TraceIDGenerator interface {
 	SetSessionId(sessionShortId string, sessionType types.SessionType)
 }
 
+// sessionIDValidator is implemented by trace id generators that cannot tag
+// traces with every short session id, like the one of the trace package
+type sessionIDValidator interface {
+	ValidateSessionId(sessionShortId string, sessionType types.SessionType) error
+}
+
+// SessionRecorder is safe for concurrent use. All state changes go through
+// the state machine in state.go and are guarded by mutex.
 type SessionRecorder struct {
+	mutex                   sync.Mutex
 	isInitialized           bool
 	shortSessionID          string
+	session                 Session
 	traceIDGenerator        TraceIDGenerator
 	sessionType             types.SessionType
 	sessionState            SessionState
 	apiService              *APIService
 	sessionShortIDGenerator func() string
 	resourceAttributes      map[string]interface{}
+	reportPauseResume       bool
+	contextScoped           bool
+	remoteControlRunning    bool
+	generateShortIDLocally  bool
+	registration            *sessionRegistration
+	journal                 *journal
+	offlineReplayInterval   time.Duration
+	journalReplayRunning    bool
+	journalReplayStopped    bool
+	journalReplayCancel     context.CancelFunc
+	journalReplayDone       chan struct{}
+	replayMutex             sync.Mutex
+	listeners               []SessionListener
+	maxSessionDuration      time.Duration
+	idleTimeout             time.Duration
+	activityTracked         bool
+	expiry                  *sessionExpiry
+	providers               []Provider
+	flushers                []Flusher
 }
 
+// NewSessionRecorder creates a session recorder that must be initialized with
+// Init. Use New to create an initialized one.
 func NewSessionRecorder() *SessionRecorder {
 	return &SessionRecorder{
 		isInitialized:           false,
@@ -52,13 +138,51 @@ func NewSessionRecorder() *SessionRecorder {
 	}
 }
 
+// New creates an initialized session recorder. The config is read from the
+// environment (see ConfigFromEnv) and the options are applied on top of it;
+// all misconfigured fields are reported at once.
+func New(opts ...Option) (*SessionRecorder, error) {
+	config, envErr := ConfigFromEnv()
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	if err := errors.Join(envErr, config.validate()); err != nil {
+		return nil, err
+	}
+
+	sr := NewSessionRecorder()
+	if err := sr.Init(config); err != nil {
+		return nil, err
+	}
+	return sr, nil
+}
+
 func (sr *SessionRecorder) Init(config SessionRecorderConfig) error {
-	if config.APIKey == "" {
-		return errors.New("api key not provided")
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	var j *journal
+	if config.OfflineJournalPath != "" {
+		var err error
+		if j, err = newJournal(config.OfflineJournalPath); err != nil {
+			return err
+		}
 	}
 
-	if config.TraceIDGenerator == nil {
-		return errors.New("incompatible trace id generator")
+	sr.mutex.Lock()
+	defer func() {
+		sr.mutex.Unlock()
+		if j != nil && j.pending() {
+			sr.ensureJournalReplay()
+		}
+	}()
+
+	sr.journal = j
+	sr.offlineReplayInterval = config.OfflineReplayInterval
+	if sr.offlineReplayInterval <= 0 {
+		sr.offlineReplayInterval = defaultOfflineReplayInterval
 	}
 
 	sr.resourceAttributes = config.ResourceAttributes
@@ -66,23 +190,39 @@ func (sr *SessionRecorder) Init(config SessionRecorderConfig) error {
 		sr.resourceAttributes = make(map[string]interface{})
 	}
 	if _, exists := sr.resourceAttributes[constants.ATTR_MULTIPLAYER_SESSION_RECORDER_VERSION]; !exists {
-		sr.resourceAttributes[constants.ATTR_MULTIPLAYER_SESSION_RECORDER_VERSION] = getSessionRecorderVersion()
+		sr.resourceAttributes[constants.ATTR_MULTIPLAYER_SESSION_RECORDER_VERSION] = constants.MULTIPLAYER_SESSION_RECORDER_VERSION
 	}
 
+	sr.generateShortIDLocally = false
 	switch v := config.GenerateSessionShortIDLocally.(type) {
 	case func() string:
 		sr.sessionShortIDGenerator = v
+		sr.generateShortIDLocally = v != nil
 	case bool:
 		if v {
 			sr.sessionShortIDGenerator = defaultSessionShortIDGenerator
 		}
+		sr.generateShortIDLocally = v
 	}
 
 	sr.traceIDGenerator = config.TraceIDGenerator
+	sr.reportPauseResume = config.ReportPauseResume
+	sr.contextScoped = config.ContextScoped
+	sr.listeners = config.Listeners
+	sr.maxSessionDuration = config.MaxSessionDuration
+	sr.idleTimeout = config.IdleTimeout
+	sr.providers = config.Providers
+	sr.flushers = config.Flushers
 
 	apiConfig := APIServiceConfig{
 		APIKey:     config.APIKey,
 		APIBaseURL: config.APIBaseURL,
+		Retry:      config.Retry,
+		HTTPClient: config.HTTPClient,
+		Transport:  config.Transport,
+		Timeout:    config.Timeout,
+		UserAgent:  config.UserAgent,
+		Headers:    config.Headers,
 	}
 	sr.apiService.Init(apiConfig)
 
@@ -90,72 +230,209 @@ func (sr *SessionRecorder) Init(config SessionRecorderConfig) error {
 	return nil
 }
 
+// State returns the current state of the session recorder
+func (sr *SessionRecorder) State() SessionState {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.sessionState
+}
+
+// ShortSessionID returns the short id of the active session, or an empty
+// string when no session is active
+func (sr *SessionRecorder) ShortSessionID() string {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.shortSessionID
+}
+
+// SessionType returns the type of the current session
+func (sr *SessionRecorder) SessionType() types.SessionType {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.sessionType
+}
+
+// ActiveSession implements types.ContextSession. ok is true while the
+// session is started.
+func (sr *SessionRecorder) ActiveSession() (string, types.SessionType, bool) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.shortSessionID, sr.sessionType, sr.sessionState == SessionStateStarted && sr.shortSessionID != ""
+}
+
 func (sr *SessionRecorder) Start(sessionType types.SessionType, sessionPayload *Session) error {
+	return sr.StartContext(context.Background(), sessionType, sessionPayload)
+}
+
+// StartContext starts a new session. The context is used for the API request.
+// When short session ids are generated locally, the session starts tagging
+// traces right away and is registered with the API in the background.
+func (sr *SessionRecorder) StartContext(ctx context.Context, sessionType types.SessionType, sessionPayload *Session) error {
+	return sr.start(ctx, SessionTriggerManual, sessionType, sessionPayload)
+}
+
+func (sr *SessionRecorder) start(ctx context.Context, trigger SessionTrigger, sessionType types.SessionType, sessionPayload *Session) (err error) {
+	event := SessionEvent{Operation: SessionOperationStart, Trigger: trigger, SessionType: sessionType}
+	defer func() {
+		if err == nil {
+			sr.watchExpiry()
+		}
+		sr.emit(ctx, event, err)
+	}()
+
+	sr.mutex.Lock()
 	if !sr.isInitialized {
-		return errors.New("configuration not initialized. Call Init() before performing any actions")
+		sr.mutex.Unlock()
+		return ErrNotInitialized
 	}
-
-	if sessionPayload != nil && sessionPayload.ShortID != "" && len(sessionPayload.ShortID) != constants.MULTIPLAYER_TRACE_DEBUG_SESSION_SHORT_ID_LENGTH {
-		return errors.New("invalid short session id")
+	if sessionPayload != nil && sessionPayload.ShortID != "" {
+		if err := validateShortSessionID(sr.traceIDGenerator, sessionPayload.ShortID, sessionType); err != nil {
+			sr.mutex.Unlock()
+			return err
+		}
 	}
-
-	if sr.sessionState != SessionStateStopped {
-		return errors.New("session should be ended before starting new one")
+	if err := sr.transition(SessionStateStarting); err != nil {
+		sr.mutex.Unlock()
+		return err
 	}
+	sr.sessionType = sessionType
+	sr.registration = nil
+	generateShortIDLocally := sr.generateShortIDLocally
+	sessionShortIDGenerator := sr.sessionShortIDGenerator
+	sr.mutex.Unlock()
 
 	if sessionPayload == nil {
 		sessionPayload = &Session{}
 	}
 
-	sr.sessionType = sessionType
-
 	if sessionPayload.Name == "" {
 		sessionPayload.Name = fmt.Sprintf("Session on %s", getFormattedDate(time.Now()))
 	}
 
-	if sessionPayload.ResourceAttributes == nil {
-		sessionPayload.ResourceAttributes = make(map[string]interface{})
+	sr.mergeResourceAttributes(sessionPayload)
+	event.Session = *sessionPayload
+
+	if generateShortIDLocally {
+		if sessionPayload.ShortID == "" {
+			sessionPayload.ShortID = sessionShortIDGenerator()
+		}
+		event.Session = *sessionPayload
+		return sr.startLocally(ctx, trigger, sessionType, *sessionPayload)
 	}
-	for k, v := range sr.resourceAttributes {
-		sessionPayload.ResourceAttributes[k] = v
+
+	idempotencyKey := newIdempotencyKey()
+	session, err := sr.startSession(ctx, sessionType, *sessionPayload, idempotencyKey)
+	if j := sr.getJournal(); j != nil && isOfflineError(ctx, err) {
+		if sessionPayload.ShortID == "" {
+			sessionPayload.ShortID = sessionShortIDGenerator()
+		}
+		event.Session = *sessionPayload
+		return sr.startOffline(j, sessionType, *sessionPayload, idempotencyKey)
 	}
 
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if err != nil {
+		sr.transition(SessionStateStopped)
+		return err
+	}
+
+	event.Session = *session
+	sr.session = *session
+	sr.shortSessionID = session.ShortID
+	sr.setTraceSessionId(sr.shortSessionID, sr.sessionType)
+	return sr.transition(SessionStateStarted)
+}
+
+// startSession starts the session through the API. The request carries
+// idempotencyKey, or a new key when it is empty.
+func (sr *SessionRecorder) startSession(ctx context.Context, sessionType types.SessionType, sessionPayload Session, idempotencyKey string) (*Session, error) {
 	var session *Session
 	var err error
 
-	if sr.sessionType == types.SESSION_TYPE_CONTINUOUS {
-		session, err = sr.apiService.StartContinuousSession(*sessionPayload)
+	startCtx := withIdempotencyKey(ctx, idempotencyKey)
+	if sessionType == types.SESSION_TYPE_CONTINUOUS {
+		session, err = sr.apiService.StartContinuousSessionContext(startCtx, sessionPayload)
 	} else {
-		session, err = sr.apiService.StartSession(*sessionPayload)
+		session, err = sr.apiService.StartSessionContext(startCtx, sessionPayload)
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if session == nil || session.ShortID == "" {
-		return errors.New("failed to start session")
+		return nil, ErrSessionNotStarted
 	}
 
-	sr.shortSessionID = session.ShortID
-	sr.traceIDGenerator.SetSessionId(sr.shortSessionID, sr.sessionType)
-	sr.sessionState = SessionStateStarted
+	sr.mutex.Lock()
+	err = validateShortSessionID(sr.traceIDGenerator, session.ShortID, sessionType)
+	sr.mutex.Unlock()
+	if err != nil {
+		// the session would stay empty, end it rather than leave it open
+		if sessionType == types.SESSION_TYPE_CONTINUOUS {
+			sr.apiService.StopContinuousSessionContext(ctx, session.ShortID)
+		} else {
+			sr.apiService.CancelSessionContext(ctx, session.ShortID)
+		}
+		return nil, err
+	}
 
+	return session, nil
+}
+
+// validateShortSessionID checks that traces can be tagged with the short id
+func validateShortSessionID(traceIDGenerator TraceIDGenerator, shortSessionID string, sessionType types.SessionType) error {
+	if len(shortSessionID) != constants.MULTIPLAYER_TRACE_DEBUG_SESSION_SHORT_ID_LENGTH {
+		return ErrInvalidShortSessionID
+	}
+	if validator, ok := traceIDGenerator.(sessionIDValidator); ok {
+		if err := validator.ValidateSessionId(shortSessionID, sessionType); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidShortSessionID, err)
+		}
+	}
 	return nil
 }
 
 func (sr *SessionRecorder) Save(sessionData *Session) error {
+	return sr.SaveContext(context.Background(), sessionData)
+}
+
+// SaveContext saves the active continuous session. The context is used for the API request.
+func (sr *SessionRecorder) SaveContext(ctx context.Context, sessionData *Session) error {
+	return sr.save(ctx, SessionTriggerManual, sessionData)
+}
+
+func (sr *SessionRecorder) save(ctx context.Context, trigger SessionTrigger, sessionData *Session) (err error) {
+	event := SessionEvent{Operation: SessionOperationSave, Trigger: trigger, SessionType: types.SESSION_TYPE_CONTINUOUS}
+	defer func() {
+		sr.emit(ctx, event, err)
+	}()
+
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
+	}
+
+	sr.mutex.Lock()
 	if !sr.isInitialized {
-		return errors.New("configuration not initialized. Call Init() before performing any actions")
+		sr.mutex.Unlock()
+		return ErrNotInitialized
 	}
 
-	if sr.sessionState == SessionStateStopped || sr.shortSessionID == "" {
-		return errors.New("session should be active or paused")
+	if !sr.isActive() {
+		sr.mutex.Unlock()
+		return ErrNoActiveSession
 	}
 
 	if sr.sessionType != types.SESSION_TYPE_CONTINUOUS {
-		return errors.New("invalid session type")
+		sr.mutex.Unlock()
+		return ErrInvalidSessionType
 	}
+	shortSessionID := sr.shortSessionID
+	event.Session = sr.session
+	event.Session.ShortID = shortSessionID
+	sr.mutex.Unlock()
 
 	if sessionData == nil {
 		sessionData = &Session{}
@@ -164,103 +441,514 @@ func (sr *SessionRecorder) Save(sessionData *Session) error {
 	if sessionData.Name == "" {
 		sessionData.Name = fmt.Sprintf("Session on %s", getFormattedDate(time.Now()))
 	}
+	event.Session.Name = sessionData.Name
+	event.Session.SessionAttributes = sessionData.SessionAttributes
+
+	flushErr, err := sr.saveContinuous(ctx, shortSessionID, *sessionData)
+	return errors.Join(flushErr, err)
+}
+
+// saveContinuous flushes the providers and flushers and saves a continuous session. Buffered
+// spans and logs must reach the backend before it saves the window, the
+// session is saved even if they could not be sent.
+func (sr *SessionRecorder) saveContinuous(ctx context.Context, shortSessionID string, sessionData Session) (flushErr, err error) {
+	flushErr = sr.flush(ctx)
+
+	err = sr.callOrJournal(ctx, journalEntry{
+		Operation:   journalOperationSave,
+		SessionType: types.SESSION_TYPE_CONTINUOUS,
+		ShortID:     shortSessionID,
+		Session:     sessionData,
+	}, func(ctx context.Context) error {
+		return sr.apiService.SaveContinuousSessionContext(ctx, shortSessionID, sessionData)
+	})
+	return flushErr, err
+}
+
+// flush force flushes the providers, then runs the flushers set in the config
+func (sr *SessionRecorder) flush(ctx context.Context) error {
+	sr.mutex.Lock()
+	providers := sr.providers
+	flushers := sr.flushers
+	sr.mutex.Unlock()
+
+	var errs []error
+	providerCtx := context.WithValue(ctx, savingContextKey{}, true)
+	for _, provider := range providers {
+		if err := provider.ForceFlush(providerCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, flusher := range flushers {
+		if err := flusher.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return sr.apiService.SaveContinuousSession(sr.shortSessionID, *sessionData)
+type savingContextKey struct{}
+
+// isSaving reports whether ctx is the context a save force flushes the
+// providers with. The processors of the session recorder must not wait for
+// or start a save then, as the save waits for them.
+func isSaving(ctx context.Context) bool {
+	saving, _ := ctx.Value(savingContextKey{}).(bool)
+	return saving
 }
 
 func (sr *SessionRecorder) Stop(sessionData *Session) error {
+	return sr.StopContext(context.Background(), sessionData)
+}
+
+// StopContext stops the active session. The context is used for the API
+// requests.
+//
+// Manual sessions are stopped with sessionData as their final attributes.
+// Continuous sessions are saved with sessionData first when it is not nil,
+// then stopped without saving the rest of their data.
+//
+// The local session is only cleared once the API confirmed the stop, or
+// journaled it in offline mode. When the API request fails, the session goes
+// back to its previous state, keeps being recorded and Stop can be retried.
+func (sr *SessionRecorder) StopContext(ctx context.Context, sessionData *Session) error {
+	return sr.stop(ctx, SessionTriggerManual, sessionData)
+}
+
+func (sr *SessionRecorder) stop(ctx context.Context, trigger SessionTrigger, sessionData *Session) (err error) {
+	event := SessionEvent{Operation: SessionOperationStop, Trigger: trigger}
 	defer func() {
-		sr.traceIDGenerator.SetSessionId("", types.SESSION_TYPE_MANUAL)
-		sr.shortSessionID = ""
-		sr.sessionState = SessionStateStopped
+		sr.emit(ctx, event, err)
 	}()
 
-	if !sr.isInitialized {
-		return errors.New("configuration not initialized. Call Init() before performing any actions")
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
 	}
 
-	if sr.sessionState == SessionStateStopped || sr.shortSessionID == "" {
-		return errors.New("session should be active or paused")
+	session, sessionType, previousState, err := sr.beginStop()
+	if err != nil {
+		return err
 	}
+	shortSessionID := session.ShortID
+	event.Session = session
+	event.SessionType = sessionType
+
+	var flushErr, apiErr error
+	defer func() {
+		sr.finishStop(previousState, apiErr)
+	}()
 
-	if sr.sessionType != types.SESSION_TYPE_MANUAL {
-		return errors.New("invalid session type")
+	if sessionType == types.SESSION_TYPE_CONTINUOUS {
+		flushErr, apiErr = sr.stopContinuous(ctx, shortSessionID, sessionData)
+		return errors.Join(flushErr, apiErr)
 	}
 
 	if sessionData == nil {
 		sessionData = &Session{}
 	}
 
-	return sr.apiService.StopSession(sr.shortSessionID, *sessionData)
+	apiErr = sr.callOrJournal(ctx, journalEntry{
+		Operation:   journalOperationStop,
+		SessionType: sessionType,
+		ShortID:     shortSessionID,
+		Session:     *sessionData,
+	}, func(ctx context.Context) error {
+		return sr.apiService.StopSessionContext(ctx, shortSessionID, *sessionData)
+	})
+	return apiErr
+}
+
+// stopContinuous saves a continuous session when sessionData is not nil and
+// stops it. The session is not stopped when the save fails.
+func (sr *SessionRecorder) stopContinuous(ctx context.Context, shortSessionID string, sessionData *Session) (flushErr, err error) {
+	if sessionData != nil {
+		if sessionData.Name == "" {
+			sessionData.Name = fmt.Sprintf("Session on %s", getFormattedDate(time.Now()))
+		}
+
+		flushErr, err = sr.saveContinuous(ctx, shortSessionID, *sessionData)
+		if err != nil {
+			return flushErr, err
+		}
+	}
+
+	err = sr.callOrJournal(ctx, journalEntry{
+		Operation:   journalOperationCancel,
+		SessionType: types.SESSION_TYPE_CONTINUOUS,
+		ShortID:     shortSessionID,
+	}, func(ctx context.Context) error {
+		return sr.apiService.StopContinuousSessionContext(ctx, shortSessionID)
+	})
+	return flushErr, err
 }
 
 func (sr *SessionRecorder) Cancel() error {
+	return sr.CancelContext(context.Background())
+}
+
+// CancelContext cancels the active session. The context is used for the API
+// request. Like StopContext, the local session is only cleared once the API
+// confirmed the cancel.
+func (sr *SessionRecorder) CancelContext(ctx context.Context) error {
+	return sr.cancel(ctx, SessionTriggerManual)
+}
+
+func (sr *SessionRecorder) cancel(ctx context.Context, trigger SessionTrigger) (err error) {
+	event := SessionEvent{Operation: SessionOperationCancel, Trigger: trigger}
 	defer func() {
-		sr.traceIDGenerator.SetSessionId("", types.SESSION_TYPE_MANUAL)
-		sr.shortSessionID = ""
-		sr.sessionState = SessionStateStopped
+		sr.emit(ctx, event, err)
 	}()
 
-	if !sr.isInitialized {
-		return errors.New("configuration not initialized. Call Init() before performing any actions")
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
+	}
+
+	session, sessionType, previousState, err := sr.beginStop()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		sr.finishStop(previousState, err)
+	}()
+	shortSessionID := session.ShortID
+	event.Session = session
+	event.SessionType = sessionType
+
+	return sr.callOrJournal(ctx, journalEntry{
+		Operation:   journalOperationCancel,
+		SessionType: sessionType,
+		ShortID:     shortSessionID,
+	}, func(ctx context.Context) error {
+		if sessionType == types.SESSION_TYPE_CONTINUOUS {
+			return sr.apiService.StopContinuousSessionContext(ctx, shortSessionID)
+		} else if sessionType == types.SESSION_TYPE_MANUAL {
+			return sr.apiService.CancelSessionContext(ctx, shortSessionID)
+		}
+		return nil
+	})
+}
+
+// UpdateSession changes the metadata of the active session, manual or
+// continuous. A non-empty Name replaces the name of the session, while
+// SessionAttributes and Tags are merged into the ones the session already
+// has. Other fields of patch are ignored. The context is used for the API
+// request.
+func (sr *SessionRecorder) UpdateSession(ctx context.Context, patch *Session) error {
+	return sr.update(ctx, SessionTriggerManual, patch)
+}
+
+func (sr *SessionRecorder) update(ctx context.Context, trigger SessionTrigger, patch *Session) (err error) {
+	event := SessionEvent{Operation: SessionOperationUpdate, Trigger: trigger}
+	defer func() {
+		sr.emit(ctx, event, err)
+	}()
+
+	if patch == nil {
+		patch = &Session{}
+	}
+	update := Session{
+		Name:              patch.Name,
+		SessionAttributes: patch.SessionAttributes,
+		Tags:              patch.Tags,
 	}
 
-	if sr.sessionState == SessionStateStopped || sr.shortSessionID == "" {
-		return errors.New("session should be active or paused")
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
 	}
 
-	if sr.sessionType == types.SESSION_TYPE_CONTINUOUS {
-		return sr.apiService.StopContinuousSession(sr.shortSessionID)
-	} else if sr.sessionType == types.SESSION_TYPE_MANUAL {
-		return sr.apiService.CancelSession(sr.shortSessionID)
+	sr.mutex.Lock()
+	if !sr.isInitialized {
+		sr.mutex.Unlock()
+		return ErrNotInitialized
+	}
+
+	if !sr.isActive() {
+		sr.mutex.Unlock()
+		return ErrNoActiveSession
+	}
+	shortSessionID := sr.shortSessionID
+	sessionType := sr.sessionType
+	event.SessionType = sessionType
+	event.Session = mergeSession(sr.session, update)
+	event.Session.ShortID = shortSessionID
+	sr.mutex.Unlock()
+
+	err = sr.callOrJournal(ctx, journalEntry{
+		Operation:   journalOperationUpdate,
+		SessionType: sessionType,
+		ShortID:     shortSessionID,
+		Session:     update,
+	}, func(ctx context.Context) error {
+		if sessionType == types.SESSION_TYPE_CONTINUOUS {
+			return sr.apiService.UpdateContinuousSessionContext(ctx, shortSessionID, update)
+		}
+		return sr.apiService.UpdateSessionContext(ctx, shortSessionID, update)
+	})
+	if err != nil {
+		return err
 	}
 
+	// the session may have been stopped or replaced while the request was
+	// in flight
+	sr.mutex.Lock()
+	if sr.isActive() && sr.shortSessionID == shortSessionID {
+		sr.session = mergeSession(sr.session, update)
+	}
+	sr.mutex.Unlock()
 	return nil
 }
 
-func (sr *SessionRecorder) CheckRemoteContinuousSession(sessionPayload *Session) error {
+// mergeSession applies the changes of UpdateSession to a copy of session
+func mergeSession(session Session, update Session) Session {
+	if update.Name != "" {
+		session.Name = update.Name
+	}
+
+	if len(update.SessionAttributes) > 0 {
+		sessionAttributes := make(map[string]interface{}, len(session.SessionAttributes)+len(update.SessionAttributes))
+		for k, v := range session.SessionAttributes {
+			sessionAttributes[k] = v
+		}
+		for k, v := range update.SessionAttributes {
+			sessionAttributes[k] = v
+		}
+		session.SessionAttributes = sessionAttributes
+	}
+
+	if len(update.Tags) > 0 {
+		tags := make(map[string]string, len(session.Tags)+len(update.Tags))
+		for k, v := range session.Tags {
+			tags[k] = v
+		}
+		for k, v := range update.Tags {
+			tags[k] = v
+		}
+		session.Tags = tags
+	}
+
+	return session
+}
+
+func (sr *SessionRecorder) Pause() error {
+	return sr.PauseContext(context.Background())
+}
+
+// PauseContext pauses the active session. While paused, new traces are not
+// tagged with the session prefix. The session is paused locally before it is
+// reported to the API, so it stays paused even if reporting fails.
+func (sr *SessionRecorder) PauseContext(ctx context.Context) error {
+	sr.mutex.Lock()
+	if !sr.isInitialized {
+		sr.mutex.Unlock()
+		return ErrNotInitialized
+	}
+	// STOPPING can only go back to PAUSED when a stop fails
+	if sr.sessionState == SessionStateStopping {
+		err := &StateTransitionError{From: sr.sessionState, To: SessionStatePaused}
+		sr.mutex.Unlock()
+		return err
+	}
+	if err := sr.transition(SessionStatePaused); err != nil {
+		sr.mutex.Unlock()
+		return err
+	}
+	sr.pauseExpiry(true)
+	sr.setTraceSessionId("", sr.sessionType)
+	reportPauseResume := sr.reportPauseResume
+	sr.mutex.Unlock()
+
+	if !reportPauseResume {
+		return nil
+	}
+
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
+	}
+	shortSessionID := sr.ShortSessionID()
+	sessionType := sr.SessionType()
+
+	if sessionType == types.SESSION_TYPE_CONTINUOUS {
+		return sr.apiService.PauseContinuousSessionContext(ctx, shortSessionID)
+	}
+	return sr.apiService.PauseSessionContext(ctx, shortSessionID)
+}
+
+func (sr *SessionRecorder) Resume() error {
+	return sr.ResumeContext(context.Background())
+}
+
+// ResumeContext resumes a paused session, new traces are tagged with the
+// session prefix again.
+func (sr *SessionRecorder) ResumeContext(ctx context.Context) error {
+	sr.mutex.Lock()
 	if !sr.isInitialized {
-		return errors.New("configuration not initialized. Call Init() before performing any actions")
+		sr.mutex.Unlock()
+		return ErrNotInitialized
+	}
+	if sr.sessionState != SessionStatePaused {
+		err := &StateTransitionError{From: sr.sessionState, To: SessionStateStarted}
+		sr.mutex.Unlock()
+		return err
+	}
+	sr.transition(SessionStateStarted)
+	sr.pauseExpiry(false)
+	sr.setTraceSessionId(sr.shortSessionID, sr.sessionType)
+	reportPauseResume := sr.reportPauseResume
+	sr.mutex.Unlock()
+
+	if !reportPauseResume {
+		return nil
+	}
+
+	if err := sr.awaitRegistration(ctx); err != nil {
+		return err
+	}
+	shortSessionID := sr.ShortSessionID()
+	sessionType := sr.SessionType()
+
+	if sessionType == types.SESSION_TYPE_CONTINUOUS {
+		return sr.apiService.ResumeContinuousSessionContext(ctx, shortSessionID)
+	}
+	return sr.apiService.ResumeSessionContext(ctx, shortSessionID)
+}
+
+func (sr *SessionRecorder) CheckRemoteContinuousSession(sessionPayload *Session) error {
+	return sr.CheckRemoteContinuousSessionContext(context.Background(), sessionPayload)
+}
+
+// CheckRemoteContinuousSessionContext checks whether a continuous session was
+// started or stopped remotely and applies that state locally. The context is
+// used for all API requests made during the check.
+func (sr *SessionRecorder) CheckRemoteContinuousSessionContext(ctx context.Context, sessionPayload *Session) error {
+	sr.mutex.Lock()
+	isInitialized := sr.isInitialized
+	sr.mutex.Unlock()
+
+	if !isInitialized {
+		return ErrNotInitialized
 	}
 
 	if sessionPayload == nil {
 		sessionPayload = &Session{}
 	}
 
-	if sessionPayload.ResourceAttributes == nil {
-		sessionPayload.ResourceAttributes = make(map[string]interface{})
+	sr.mergeResourceAttributes(sessionPayload)
+
+	response, err := sr.apiService.CheckRemoteSessionContext(ctx, *sessionPayload)
+	if err != nil {
+		return err
 	}
-	for k, v := range sr.resourceAttributes {
-		sessionPayload.ResourceAttributes[k] = v
+
+	// Sessions that are starting or stopping are left alone, the transition
+	// in progress decides the final state. Manual sessions are never stopped
+	// remotely.
+	sr.mutex.Lock()
+	state := sr.sessionState
+	sessionType := sr.sessionType
+	sr.mutex.Unlock()
+	if response.State == "START" && state == SessionStateStopped {
+		err = sr.start(ctx, SessionTriggerRemote, types.SESSION_TYPE_CONTINUOUS, sessionPayload)
+	} else if response.State == "STOP" && (state == SessionStateStarted || state == SessionStatePaused) && sessionType == types.SESSION_TYPE_CONTINUOUS {
+		err = sr.stop(ctx, SessionTriggerRemote, nil)
+	} else {
+		return nil
 	}
 
-	response, err := sr.apiService.CheckRemoteSession(*sessionPayload)
 	if err != nil {
 		return err
 	}
 
-	if response.State == "START" && sr.sessionState != SessionStateStarted {
-		return sr.Start(types.SESSION_TYPE_CONTINUOUS, sessionPayload)
-	} else if response.State == "STOP" && sr.sessionState != SessionStateStopped {
-		return sr.Stop(nil)
+	sr.emitRemoteStateChange(ctx, RemoteStateChange{
+		PreviousState: state,
+		State:         sr.State(),
+	})
+	return nil
+}
+
+// isActive reports whether a session is started or paused.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) isActive() bool {
+	return (sr.sessionState == SessionStateStarted || sr.sessionState == SessionStatePaused) && sr.shortSessionID != ""
+}
+
+// beginStop moves an active session into STOPPING and returns the session,
+// its type and the state it was in. Every successful call must be followed by
+// finishStop.
+func (sr *SessionRecorder) beginStop() (Session, types.SessionType, SessionState, error) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if !sr.isInitialized {
+		return Session{}, sr.sessionType, sr.sessionState, ErrNotInitialized
+	}
+
+	previousState := sr.sessionState
+	if err := sr.transition(SessionStateStopping); err != nil {
+		return Session{}, sr.sessionType, previousState, err
 	}
 
-	return nil
+	session := sr.session
+	session.ShortID = sr.shortSessionID
+	return session, sr.sessionType, previousState, nil
 }
 
-func defaultSessionShortIDGenerator() string {
-	chars := "abcdefghijklmnopqrstuvwxyz0123456789"
-	result := make([]byte, constants.MULTIPLAYER_TRACE_DEBUG_SESSION_SHORT_ID_LENGTH)
-	for i := range result {
-		result[i] = chars[time.Now().UnixNano()%int64(len(chars))]
+// finishStop ends a stop begun by beginStop. The local session is cleared and
+// moved into STOPPED when the API confirmed the stop or reported that the
+// session does not exist. Otherwise, conflicts included, it goes back to
+// previousState.
+func (sr *SessionRecorder) finishStop(previousState SessionState, err error) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if err != nil && !isSessionEnded(err) {
+		sr.transition(previousState)
+		return
 	}
-	return string(result)
+
+	sr.clearSession()
+	sr.transition(SessionStateStopped)
 }
 
-func getFormattedDate(t time.Time) string {
-	return t.Format("2006-01-02 15:04:05")
+// clearSession stops tagging traces and forgets the current session.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) clearSession() {
+	sr.setTraceSessionId("", types.SESSION_TYPE_MANUAL)
+	sr.shortSessionID = ""
+	sr.session = Session{}
+	sr.stopExpiry()
+}
+
+// setTraceSessionId updates the session tagged by the trace id generator.
+// Context scoped sessions are never set on the generator.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) setTraceSessionId(sessionShortId string, sessionType types.SessionType) {
+	if sr.contextScoped {
+		return
+	}
+	sr.traceIDGenerator.SetSessionId(sessionShortId, sessionType)
 }
 
-func getSessionRecorderVersion() string {
-	return "1.0.0"
+func (sr *SessionRecorder) mergeResourceAttributes(sessionPayload *Session) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if sessionPayload.ResourceAttributes == nil {
+		sessionPayload.ResourceAttributes = make(map[string]interface{})
+	}
+	for k, v := range sr.resourceAttributes {
+		sessionPayload.ResourceAttributes[k] = v
+	}
+}
+
+// defaultSessionShortIDGenerator returns a random short session id of 16
+// lowercase hex characters, read from crypto/rand. Trace ids carry the short
+// id as hex, see the trace package.
+func defaultSessionShortIDGenerator() string {
+	buf := make([]byte, constants.MULTIPLAYER_TRACE_DEBUG_SESSION_SHORT_ID_LENGTH/2)
+	crand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func getFormattedDate(t time.Time) string {
+	return t.Format("2006-01-02 15:04:05")
 }
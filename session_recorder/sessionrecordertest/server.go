@@ -0,0 +1,421 @@
+// Package sessionrecordertest provides an in-process fake of the Multiplayer
+// API for tests of code that uses session_recorder.
+package sessionrecordertest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// APIKey is the API key accepted by a Server
+const APIKey = "sessionrecordertest-api-key"
+
+// Endpoint identifies an endpoint of the Multiplayer API
+type Endpoint string
+
+const (
+	EndpointStartSession            Endpoint = "start-session"
+	EndpointStopSession             Endpoint = "stop-session"
+	EndpointCancelSession           Endpoint = "cancel-session"
+	EndpointPauseSession            Endpoint = "pause-session"
+	EndpointResumeSession           Endpoint = "resume-session"
+	EndpointUpdateSession           Endpoint = "update-session"
+	EndpointStartContinuousSession  Endpoint = "start-continuous-session"
+	EndpointSaveContinuousSession   Endpoint = "save-continuous-session"
+	EndpointCancelContinuousSession Endpoint = "cancel-continuous-session"
+	EndpointPauseContinuousSession  Endpoint = "pause-continuous-session"
+	EndpointResumeContinuousSession Endpoint = "resume-continuous-session"
+	EndpointUpdateContinuousSession Endpoint = "update-continuous-session"
+	EndpointCheckRemoteSession      Endpoint = "check-remote-session"
+)
+
+// SessionState is the state of a session known to a Server
+type SessionState string
+
+const (
+	SessionStateStarted  SessionState = "started"
+	SessionStatePaused   SessionState = "paused"
+	SessionStateStopped  SessionState = "stopped"
+	SessionStateCanceled SessionState = "canceled"
+)
+
+// Call is a request received by a Server
+type Call struct {
+	Endpoint Endpoint
+	Method   string
+	Path     string
+	// ShortID is the short session id in the path
+	ShortID    string
+	Header     http.Header
+	Body       []byte
+	StatusCode int
+}
+
+// Decode unmarshals the JSON body of the call into v
+func (c Call) Decode(v interface{}) error {
+	return json.Unmarshal(c.Body, v)
+}
+
+// Session is a session known to a Server
+type Session struct {
+	session_recorder.Session
+	SessionType types.SessionType
+	State       SessionState
+	// Saves holds the payloads of the saves of a continuous session
+	Saves []session_recorder.StartSessionRequest
+}
+
+type failure struct {
+	statusCode int
+	times      int
+}
+
+// Server is a fake Multiplayer API. It keeps the sessions it started, records
+// every call, and can be scripted to fail, to respond slowly, and to request
+// remote START and STOP of continuous sessions. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mutex       sync.Mutex
+	calls       []Call
+	sessions    map[string]*Session
+	failures    map[Endpoint]*failure
+	latency     time.Duration
+	remoteState string
+}
+
+// NewServer starts a Server. Close it when the test is done.
+func NewServer() *Server {
+	s := &Server{
+		sessions:    make(map[string]*Session),
+		failures:    make(map[Endpoint]*failure),
+		remoteState: "STOP",
+	}
+
+	mux := http.NewServeMux()
+	s.handle(mux, "POST", "/debug-sessions/start", EndpointStartSession, s.startSession(types.SESSION_TYPE_MANUAL))
+	s.handle(mux, "PATCH", "/debug-sessions/{id}/stop", EndpointStopSession, s.endSession(types.SESSION_TYPE_MANUAL, SessionStateStopped))
+	s.handle(mux, "DELETE", "/debug-sessions/{id}/cancel", EndpointCancelSession, s.endSession(types.SESSION_TYPE_MANUAL, SessionStateCanceled))
+	s.handle(mux, "PATCH", "/debug-sessions/{id}/pause", EndpointPauseSession, s.setSessionState(types.SESSION_TYPE_MANUAL, SessionStateStarted, SessionStatePaused))
+	s.handle(mux, "PATCH", "/debug-sessions/{id}/resume", EndpointResumeSession, s.setSessionState(types.SESSION_TYPE_MANUAL, SessionStatePaused, SessionStateStarted))
+	s.handle(mux, "PATCH", "/debug-sessions/{id}", EndpointUpdateSession, s.updateSession(types.SESSION_TYPE_MANUAL))
+	s.handle(mux, "POST", "/continuous-debug-sessions/start", EndpointStartContinuousSession, s.startSession(types.SESSION_TYPE_CONTINUOUS))
+	s.handle(mux, "POST", "/continuous-debug-sessions/{id}/save", EndpointSaveContinuousSession, s.saveSession)
+	s.handle(mux, "DELETE", "/continuous-debug-sessions/{id}/cancel", EndpointCancelContinuousSession, s.endSession(types.SESSION_TYPE_CONTINUOUS, SessionStateCanceled))
+	s.handle(mux, "PATCH", "/continuous-debug-sessions/{id}/pause", EndpointPauseContinuousSession, s.setSessionState(types.SESSION_TYPE_CONTINUOUS, SessionStateStarted, SessionStatePaused))
+	s.handle(mux, "PATCH", "/continuous-debug-sessions/{id}/resume", EndpointResumeContinuousSession, s.setSessionState(types.SESSION_TYPE_CONTINUOUS, SessionStatePaused, SessionStateStarted))
+	s.handle(mux, "PATCH", "/continuous-debug-sessions/{id}", EndpointUpdateContinuousSession, s.updateSession(types.SESSION_TYPE_CONTINUOUS))
+	s.handle(mux, "POST", "/remote-debug-session/check", EndpointCheckRemoteSession, s.checkRemoteSession)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Options returns the options that point a session recorder at the server
+func (s *Server) Options() []session_recorder.Option {
+	return []session_recorder.Option{
+		session_recorder.WithAPIKey(APIKey),
+		session_recorder.WithAPIBaseURL(s.URL),
+	}
+}
+
+// Calls returns the calls received so far, in order
+func (s *Server) Calls() []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// CallsTo returns the calls received by an endpoint, in order
+func (s *Server) CallsTo(endpoint Endpoint) []Call {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var calls []Call
+	for _, call := range s.calls {
+		if call.Endpoint == endpoint {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// Session returns the session with the given short id
+func (s *Server) Session(shortID string) (Session, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, ok := s.sessions[shortID]
+	if !ok {
+		return Session{}, false
+	}
+	copied := *session
+	copied.Saves = append([]session_recorder.StartSessionRequest(nil), session.Saves...)
+	return copied, true
+}
+
+// Fail makes the next times calls to endpoint respond with statusCode. A
+// negative times fails every call until Reset.
+func (s *Server) Fail(endpoint Endpoint, statusCode int, times int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures[endpoint] = &failure{statusCode: statusCode, times: times}
+}
+
+// SetLatency delays every response
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = latency
+}
+
+// SetRemoteState sets the state returned by the remote session check,
+// "START" or "STOP"
+func (s *Server) SetRemoteState(state string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.remoteState = state
+}
+
+// Reset forgets calls, sessions, failures and latency
+func (s *Server) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.calls = nil
+	s.sessions = make(map[string]*Session)
+	s.failures = make(map[Endpoint]*failure)
+	s.latency = 0
+	s.remoteState = "STOP"
+}
+
+// handler serves a call with s.mutex held and returns the status code and
+// the response body
+type handler func(call *Call) (int, interface{})
+
+func (s *Server) handle(mux *http.ServeMux, method, path string, endpoint Endpoint, h handler) {
+	mux.HandleFunc(method+" /v0/radar"+path, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		call := Call{
+			Endpoint: endpoint,
+			Method:   r.Method,
+			Path:     r.URL.Path,
+			ShortID:  r.PathValue("id"),
+			Header:   r.Header.Clone(),
+			Body:     body,
+		}
+
+		s.mutex.Lock()
+		latency := s.latency
+		s.mutex.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		statusCode, response := s.serve(&call, h)
+
+		if response == nil {
+			w.WriteHeader(statusCode)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+func (s *Server) serve(call *Call, h handler) (int, interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	defer func() {
+		s.calls = append(s.calls, *call)
+	}()
+
+	if call.Header.Get("X-Api-Key") != APIKey {
+		call.StatusCode = http.StatusUnauthorized
+		return call.StatusCode, errorResponse("invalid api key")
+	}
+
+	if f, ok := s.failures[call.Endpoint]; ok && f.times != 0 {
+		if f.times > 0 {
+			f.times--
+		}
+		call.StatusCode = f.statusCode
+		return call.StatusCode, errorResponse(http.StatusText(f.statusCode))
+	}
+
+	statusCode, response := h(call)
+	call.StatusCode = statusCode
+	return statusCode, response
+}
+
+func (s *Server) startSession(sessionType types.SessionType) handler {
+	return func(call *Call) (int, interface{}) {
+		var request session_recorder.StartSessionRequest
+		if err := call.Decode(&request); err != nil {
+			return http.StatusBadRequest, errorResponse(err.Error())
+		}
+
+		shortID := request.ShortID
+		if shortID == "" {
+			shortID = randomHex(8)
+		} else if _, exists := s.sessions[shortID]; exists {
+			return http.StatusConflict, errorResponse("session already exists")
+		}
+		call.ShortID = shortID
+
+		session := &Session{
+			Session: session_recorder.Session{
+				ID:                 randomHex(12),
+				ShortID:            shortID,
+				Name:               request.Name,
+				ResourceAttributes: request.ResourceAttributes,
+				SessionAttributes:  request.SessionAttributes,
+				Tags:               tagsMap(request.Tags),
+			},
+			SessionType: sessionType,
+			State:       SessionStateStarted,
+		}
+		s.sessions[shortID] = session
+
+		return http.StatusOK, session.Session
+	}
+}
+
+func (s *Server) endSession(sessionType types.SessionType, state SessionState) handler {
+	return func(call *Call) (int, interface{}) {
+		session, ok := s.sessions[call.ShortID]
+		if !ok || session.SessionType != sessionType {
+			return http.StatusNotFound, errorResponse("session not found")
+		}
+		if session.State == SessionStateStopped || session.State == SessionStateCanceled {
+			return http.StatusConflict, errorResponse("session already ended")
+		}
+
+		if state == SessionStateStopped {
+			var request session_recorder.StopSessionRequest
+			if len(call.Body) > 0 {
+				if err := call.Decode(&request); err != nil {
+					return http.StatusBadRequest, errorResponse(err.Error())
+				}
+			}
+			for k, v := range request.SessionAttributes {
+				if session.SessionAttributes == nil {
+					session.SessionAttributes = make(map[string]interface{})
+				}
+				session.SessionAttributes[k] = v
+			}
+		}
+
+		session.State = state
+		return http.StatusNoContent, nil
+	}
+}
+
+func (s *Server) setSessionState(sessionType types.SessionType, from, to SessionState) handler {
+	return func(call *Call) (int, interface{}) {
+		session, ok := s.sessions[call.ShortID]
+		if !ok || session.SessionType != sessionType {
+			return http.StatusNotFound, errorResponse("session not found")
+		}
+		if session.State != from {
+			return http.StatusConflict, errorResponse("session is " + string(session.State))
+		}
+
+		session.State = to
+		return http.StatusNoContent, nil
+	}
+}
+
+func (s *Server) updateSession(sessionType types.SessionType) handler {
+	return func(call *Call) (int, interface{}) {
+		session, ok := s.sessions[call.ShortID]
+		if !ok || session.SessionType != sessionType {
+			return http.StatusNotFound, errorResponse("session not found")
+		}
+		if session.State != SessionStateStarted && session.State != SessionStatePaused {
+			return http.StatusConflict, errorResponse("session is " + string(session.State))
+		}
+
+		var request session_recorder.UpdateSessionRequest
+		if err := call.Decode(&request); err != nil {
+			return http.StatusBadRequest, errorResponse(err.Error())
+		}
+
+		if request.Name != "" {
+			session.Name = request.Name
+		}
+		for k, v := range request.SessionAttributes {
+			if session.SessionAttributes == nil {
+				session.SessionAttributes = make(map[string]interface{})
+			}
+			session.SessionAttributes[k] = v
+		}
+		for k, v := range tagsMap(request.Tags) {
+			if session.Tags == nil {
+				session.Tags = make(map[string]string)
+			}
+			session.Tags[k] = v
+		}
+
+		return http.StatusNoContent, nil
+	}
+}
+
+func (s *Server) saveSession(call *Call) (int, interface{}) {
+	session, ok := s.sessions[call.ShortID]
+	if !ok || session.SessionType != types.SESSION_TYPE_CONTINUOUS {
+		return http.StatusNotFound, errorResponse("session not found")
+	}
+	if session.State != SessionStateStarted && session.State != SessionStatePaused {
+		return http.StatusConflict, errorResponse("session is " + string(session.State))
+	}
+
+	var request session_recorder.StartSessionRequest
+	if err := call.Decode(&request); err != nil {
+		return http.StatusBadRequest, errorResponse(err.Error())
+	}
+
+	session.Saves = append(session.Saves, request)
+	return http.StatusNoContent, nil
+}
+
+func (s *Server) checkRemoteSession(call *Call) (int, interface{}) {
+	return http.StatusOK, session_recorder.CheckRemoteSessionResponse{State: s.remoteState}
+}
+
+func tagsMap(tags []session_recorder.Tag) map[string]string {
+	if tags == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		result[tag.Key] = tag.Value
+	}
+	return result
+}
+
+func errorResponse(message string) map[string]string {
+	return map[string]string{"message": message}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
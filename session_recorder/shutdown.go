@@ -0,0 +1,138 @@
+package session_recorder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// Provider is a tracer or logger provider, e.g. *sdktrace.TracerProvider or
+// *sdklog.LoggerProvider
+type Provider interface {
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// Exporter is a span or log exporter. Exporters that implement Flusher, like
+// the flight recorder exporters, or ForceFlush, like log exporters, are
+// drained before they are shut down.
+type Exporter interface {
+	Shutdown(ctx context.Context) error
+}
+
+type forceFlusher interface {
+	ForceFlush(ctx context.Context) error
+}
+
+type ShutdownConfig struct {
+	// SessionRecorders have their active session stopped first, then their
+	// offline journal replayed one last time
+	SessionRecorders []*SessionRecorder
+	// SaveContinuousSessions saves active continuous sessions one last time
+	// before they are stopped
+	SaveContinuousSessions bool
+	// Providers are flushed once the sessions are stopped, and shut down
+	// before the exporters
+	Providers []Provider
+	// Exporters are drained after the providers are flushed, and shut down
+	// last. Exporters registered with one of the providers are shut down by
+	// it too, the exporters of this module allow that.
+	Exporters []Exporter
+}
+
+// ShutdownCoordinator shuts down session recording in an order that does not
+// lose telemetry: active sessions are stopped or saved, offline journals are
+// replayed and their background replay stopped, providers are flushed,
+// exporters are drained, then providers and exporters are shut down.
+type ShutdownCoordinator struct {
+	config ShutdownConfig
+
+	once sync.Once
+	err  error
+}
+
+func NewShutdownCoordinator(config ShutdownConfig) *ShutdownCoordinator {
+	return &ShutdownCoordinator{config: config}
+}
+
+// Shutdown runs every step within the deadline of ctx, even when earlier
+// steps fail. It returns the errors of all failed steps joined together.
+// Later calls return the result of the first one.
+func (c *ShutdownCoordinator) Shutdown(ctx context.Context) error {
+	c.once.Do(func() {
+		c.err = c.shutdown(ctx)
+	})
+	return c.err
+}
+
+func (c *ShutdownCoordinator) shutdown(ctx context.Context) error {
+	var errs []error
+
+	for _, sr := range c.config.SessionRecorders {
+		if err := c.stopSession(ctx, sr); err != nil {
+			errs = append(errs, err)
+		}
+		if err := sr.StopJournalReplay(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to replay the offline journal: %w", err))
+		}
+	}
+
+	for _, provider := range c.config.Providers {
+		if err := provider.ForceFlush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush %T: %w", provider, err))
+		}
+	}
+
+	for _, exporter := range c.config.Exporters {
+		if err := drain(ctx, exporter); err != nil {
+			errs = append(errs, fmt.Errorf("failed to drain %T: %w", exporter, err))
+		}
+	}
+
+	for _, provider := range c.config.Providers {
+		if err := provider.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down %T: %w", provider, err))
+		}
+	}
+
+	for _, exporter := range c.config.Exporters {
+		if err := exporter.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to shut down %T: %w", exporter, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stopSession stops the active or paused session of sr, if any
+func (c *ShutdownCoordinator) stopSession(ctx context.Context, sr *SessionRecorder) error {
+	if state := sr.State(); state != SessionStateStarted && state != SessionStatePaused {
+		return nil
+	}
+	shortSessionID := sr.ShortSessionID()
+	sessionType := sr.SessionType()
+
+	var sessionData *Session
+	if sessionType == types.SESSION_TYPE_CONTINUOUS && c.config.SaveContinuousSessions {
+		sessionData = &Session{}
+	}
+
+	if err := sr.StopContext(ctx, sessionData); err != nil {
+		return fmt.Errorf("failed to stop session %s: %w", shortSessionID, err)
+	}
+	return nil
+}
+
+func drain(ctx context.Context, exporter Exporter) error {
+	var errs []error
+	if flusher, ok := exporter.(Flusher); ok {
+		errs = append(errs, flusher.Flush(ctx))
+	}
+	if flusher, ok := exporter.(forceFlusher); ok {
+		errs = append(errs, flusher.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,208 @@
+package session_recorder_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// steps records the shutdown steps in the order they run
+type steps struct {
+	mutex sync.Mutex
+	names []string
+}
+
+func (s *steps) add(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.names = append(s.names, name)
+}
+
+func (s *steps) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return strings.Join(s.names, ", ")
+}
+
+// fakeProvider records its steps. onFlush runs when it is flushed and its
+// error is returned.
+type fakeProvider struct {
+	name    string
+	steps   *steps
+	onFlush func(ctx context.Context) error
+	err     error
+}
+
+func (p *fakeProvider) ForceFlush(ctx context.Context) error {
+	p.steps.add("flush " + p.name)
+	if p.onFlush != nil {
+		return p.onFlush(ctx)
+	}
+	return nil
+}
+
+func (p *fakeProvider) Shutdown(ctx context.Context) error {
+	p.steps.add("shut down " + p.name)
+	return p.err
+}
+
+// fakeExporter records its steps, it is drained through Flush
+type fakeExporter struct {
+	name  string
+	steps *steps
+	err   error
+}
+
+func (e *fakeExporter) Flush(ctx context.Context) error {
+	e.steps.add("drain " + e.name)
+	return e.err
+}
+
+func (e *fakeExporter) Shutdown(ctx context.Context) error {
+	e.steps.add("shut down " + e.name)
+	return nil
+}
+
+func TestShutdownOrder(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	ctx := context.Background()
+	manual := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+	continuous := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator())
+	if err := manual.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := continuous.StartContext(ctx, types.SESSION_TYPE_CONTINUOUS, nil); err != nil {
+		t.Fatal(err)
+	}
+	manualID, continuousID := manual.ShortSessionID(), continuous.ShortSessionID()
+
+	s := &steps{}
+	tracerProvider := &fakeProvider{name: "tracer provider", steps: s, onFlush: func(ctx context.Context) error {
+		if manual.State() != session_recorder.SessionStateStopped || continuous.State() != session_recorder.SessionStateStopped {
+			t.Error("expected the sessions to be stopped before the providers are flushed")
+		}
+		return nil
+	}}
+	loggerProvider := &fakeProvider{name: "logger provider", steps: s}
+	exporter := &fakeExporter{name: "exporter", steps: s}
+
+	coordinator := session_recorder.NewShutdownCoordinator(session_recorder.ShutdownConfig{
+		SessionRecorders:       []*session_recorder.SessionRecorder{manual, continuous},
+		SaveContinuousSessions: true,
+		Providers:              []session_recorder.Provider{tracerProvider, loggerProvider},
+		Exporters:              []session_recorder.Exporter{exporter},
+	})
+	if err := coordinator.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "flush tracer provider, flush logger provider, drain exporter, shut down tracer provider, shut down logger provider, shut down exporter"
+	if got := s.String(); got != want {
+		t.Errorf("expected the steps %s, got %s", want, got)
+	}
+
+	assertServerSession(t, api, manualID, sessionrecordertest.SessionStateStopped)
+	// continuous sessions are stopped through the cancel endpoint once saved
+	session := assertServerSession(t, api, continuousID, sessionrecordertest.SessionStateCanceled)
+	if len(session.Saves) != 1 {
+		t.Errorf("expected the continuous session to be saved once, got %d saves", len(session.Saves))
+	}
+
+	// later calls do not run the steps again
+	if err := coordinator.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.String(); got != want {
+		t.Errorf("expected the steps to run once, got %s", got)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	s := &steps{}
+	// the tracer provider hangs until the deadline
+	tracerProvider := &fakeProvider{name: "tracer provider", steps: s, onFlush: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	exporter := &fakeExporter{name: "exporter", steps: s}
+
+	coordinator := session_recorder.NewShutdownCoordinator(session_recorder.ShutdownConfig{
+		Providers: []session_recorder.Provider{tracerProvider},
+		Exporters: []session_recorder.Exporter{exporter},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := coordinator.Shutdown(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Shutdown to return at the deadline, it took %s", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be reported, got %v", err)
+	}
+
+	// the steps after the hung one still run
+	want := "flush tracer provider, drain exporter, shut down tracer provider, shut down exporter"
+	if got := s.String(); got != want {
+		t.Errorf("expected the steps %s, got %s", want, got)
+	}
+}
+
+func TestShutdownErrors(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	sr := newSessionRecorder(t, api, multiplayer.NewSessionRecorderIdGenerator(), session_recorder.WithRetry(fastRetry))
+	if err := sr.StartContext(context.Background(), types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	api.Fail(sessionrecordertest.EndpointStopSession, http.StatusInternalServerError, -1)
+
+	errFlush := errors.New("flush failed")
+	errShutdown := errors.New("shutdown failed")
+	s := &steps{}
+	coordinator := session_recorder.NewShutdownCoordinator(session_recorder.ShutdownConfig{
+		SessionRecorders: []*session_recorder.SessionRecorder{sr},
+		Providers: []session_recorder.Provider{
+			&fakeProvider{name: "tracer provider", steps: s, err: errShutdown},
+		},
+		Exporters: []session_recorder.Exporter{
+			&fakeExporter{name: "exporter", steps: s, err: errFlush},
+		},
+	})
+
+	err := coordinator.Shutdown(context.Background())
+	var apiErr *session_recorder.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the failed stop to be reported, got %v", err)
+	}
+	if !errors.Is(err, errFlush) || !errors.Is(err, errShutdown) {
+		t.Errorf("expected the failed drain and shutdown to be reported, got %v", err)
+	}
+	for _, step := range []string{"failed to stop session", "failed to drain", "failed to shut down"} {
+		if err == nil || !strings.Contains(err.Error(), step) {
+			t.Errorf("expected %q in %v", step, err)
+		}
+	}
+
+	// a failed step does not prevent the next ones
+	want := "flush tracer provider, drain exporter, shut down tracer provider, shut down exporter"
+	if got := s.String(); got != want {
+		t.Errorf("expected the steps %s, got %s", want, got)
+	}
+	if second := coordinator.Shutdown(context.Background()); second != err {
+		t.Errorf("expected later calls to return the first error, got %v", second)
+	}
+}
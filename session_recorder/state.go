@@ -0,0 +1,34 @@
+package session_recorder
+
+// sessionStateTransitions lists the states reachable from every state:
+//
+//	STOPPED -> STARTING -> STARTED <-> PAUSED -> STOPPING -> STOPPED
+//
+// A failed start moves STARTING back to STOPPED, and a failed stop moves
+// STOPPING back to STARTED or PAUSED.
+var sessionStateTransitions = map[SessionState][]SessionState{
+	SessionStateStopped:  {SessionStateStarting},
+	SessionStateStarting: {SessionStateStarted, SessionStateStopped},
+	SessionStateStarted:  {SessionStatePaused, SessionStateStopping},
+	SessionStatePaused:   {SessionStateStarted, SessionStateStopping},
+	SessionStateStopping: {SessionStateStopped, SessionStateStarted, SessionStatePaused},
+}
+
+func canTransition(from, to SessionState) bool {
+	for _, state := range sessionStateTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// transition moves the session recorder into the given state.
+// It must be called with sr.mutex held.
+func (sr *SessionRecorder) transition(to SessionState) error {
+	if !canTransition(sr.sessionState, to) {
+		return &StateTransitionError{From: sr.sessionState, To: to}
+	}
+	sr.sessionState = to
+	return nil
+}
@@ -0,0 +1,169 @@
+package session_recorder_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+)
+
+// operations are the actions raced against each other by TestConcurrentOperations
+var operations = []func(ctx context.Context, sr *session_recorder.SessionRecorder) error{
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.StartContext(ctx, types.SESSION_TYPE_CONTINUOUS, nil)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.StopContext(ctx, nil)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.StopContext(ctx, &session_recorder.Session{Name: "stopped"})
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.CancelContext(ctx)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.PauseContext(ctx)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.ResumeContext(ctx)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.SaveContext(ctx, nil)
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.UpdateSession(ctx, &session_recorder.Session{Tags: map[string]string{"stress": "true"}})
+	},
+	func(ctx context.Context, sr *session_recorder.SessionRecorder) error {
+		return sr.CheckRemoteContinuousSessionContext(ctx, nil)
+	},
+}
+
+// failures are injected into the API between rounds
+var failures = []struct {
+	endpoint   sessionrecordertest.Endpoint
+	statusCode int
+}{
+	{sessionrecordertest.EndpointStartSession, http.StatusServiceUnavailable},
+	{sessionrecordertest.EndpointStartContinuousSession, http.StatusBadRequest},
+	{sessionrecordertest.EndpointStopSession, http.StatusConflict},
+	{sessionrecordertest.EndpointStopSession, http.StatusNotFound},
+	{sessionrecordertest.EndpointCancelSession, http.StatusInternalServerError},
+	{sessionrecordertest.EndpointCancelContinuousSession, http.StatusNotFound},
+	{sessionrecordertest.EndpointSaveContinuousSession, http.StatusConflict},
+	{sessionrecordertest.EndpointPauseSession, http.StatusConflict},
+	{sessionrecordertest.EndpointResumeContinuousSession, http.StatusServiceUnavailable},
+	{sessionrecordertest.EndpointCheckRemoteSession, http.StatusBadGateway},
+}
+
+// TestConcurrentOperations races every operation of the session recorder
+// against a slow and failing API, and checks after every round that the
+// state, the short session id, the trace id generator and the API agree.
+// Run it with -race.
+func TestConcurrentOperations(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	sr := newSessionRecorder(t, api, idGenerator,
+		session_recorder.WithReportPauseResume(true),
+		session_recorder.WithRetry(fastRetry),
+	)
+
+	rounds := 100
+	if testing.Short() {
+		rounds = 20
+	}
+
+	for round := 0; round < rounds; round++ {
+		api.SetLatency(time.Duration(rand.N(3)) * time.Millisecond)
+		failure := failures[rand.N(len(failures))]
+		api.Fail(failure.endpoint, failure.statusCode, 1+rand.N(3))
+		if rand.N(2) == 0 {
+			api.SetRemoteState("START")
+		} else {
+			api.SetRemoteState("STOP")
+		}
+
+		var wg sync.WaitGroup
+		for worker := 0; worker < 8; worker++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 4; i++ {
+					// errors are expected, most operations lose the race
+					operations[rand.N(len(operations))](context.Background(), sr)
+					sr.State()
+					sr.ShortSessionID()
+					sr.ActiveSession()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assertConsistent(t, api, sr, idGenerator)
+		if t.Failed() {
+			t.Fatalf("inconsistent after round %d", round)
+		}
+	}
+}
+
+// assertConsistent checks a session recorder with no operation in progress
+func assertConsistent(t *testing.T, api *sessionrecordertest.Server, sr *session_recorder.SessionRecorder, idGenerator *multiplayer.SessionRecorderIdGenerator) {
+	t.Helper()
+
+	state := sr.State()
+	shortSessionID := sr.ShortSessionID()
+	sessionType := sr.SessionType()
+	traceID, _ := idGenerator.NewIDs(context.Background())
+	tracedType, tracedID, traced := multiplayer.Decode(traceID)
+
+	switch state {
+	case session_recorder.SessionStateStopped:
+		if shortSessionID != "" {
+			t.Errorf("expected no short session id while stopped, got %q", shortSessionID)
+		}
+		if traced {
+			t.Errorf("expected untagged traces while stopped, got session %s", tracedID)
+		}
+	case session_recorder.SessionStatePaused:
+		if shortSessionID == "" {
+			t.Error("expected a short session id while paused")
+		}
+		if traced {
+			t.Errorf("expected untagged traces while paused, got session %s", tracedID)
+		}
+	case session_recorder.SessionStateStarted:
+		if !traced || tracedID != shortSessionID || tracedType != sessionType {
+			t.Errorf("expected traces tagged with %d session %s, got %d session %q", sessionType, shortSessionID, tracedType, tracedID)
+		}
+	default:
+		t.Fatalf("expected no transition left in progress, got %s", state)
+	}
+
+	if shortSessionID == "" {
+		return
+	}
+	// pauses and resumes that failed to be reported leave the API behind, but
+	// it must still be recording the session
+	session, ok := api.Session(shortSessionID)
+	if !ok {
+		t.Errorf("session %s not found", shortSessionID)
+		return
+	}
+	if session.State != sessionrecordertest.SessionStateStarted && session.State != sessionrecordertest.SessionStatePaused {
+		t.Errorf("expected session %s to be recording, got %s", shortSessionID, session.State)
+	}
+	if session.SessionType != sessionType {
+		t.Errorf("expected session %s to be of type %d, got %d", shortSessionID, sessionType, session.SessionType)
+	}
+}
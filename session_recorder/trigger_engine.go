@@ -0,0 +1,230 @@
+package session_recorder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	otellog "go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// AutoSaveTrigger is a condition that makes the TriggerEngine save the
+// continuous session
+type AutoSaveTrigger string
+
+const (
+	// AutoSaveTriggerHTTPServerError fires on server spans with a 5xx status code
+	AutoSaveTriggerHTTPServerError AutoSaveTrigger = "http-server-error"
+	// AutoSaveTriggerException fires on spans with a recorded exception
+	AutoSaveTriggerException AutoSaveTrigger = "exception"
+	// AutoSaveTriggerSpanError fires on spans that end with codes.Error
+	AutoSaveTriggerSpanError AutoSaveTrigger = "span-error"
+	// AutoSaveTriggerErrorLog fires on log records at MinLogSeverity or above
+	AutoSaveTriggerErrorLog AutoSaveTrigger = "error-log"
+)
+
+// defaultAutoSaveCooldown is used for triggers without a cooldown
+const defaultAutoSaveCooldown = time.Minute
+
+type TriggerEngineConfig struct {
+	// Triggers enables triggers and sets the minimum time between two saves
+	// made by each of them. A cooldown of 0 uses the default of one minute.
+	// All triggers are enabled with the default cooldown when Triggers is nil.
+	Triggers map[AutoSaveTrigger]time.Duration
+	// MinLogSeverity is the lowest severity of log records that fire
+	// AutoSaveTriggerErrorLog, otellog.SeverityError by default
+	MinLogSeverity otellog.Severity
+}
+
+// TriggerEngine saves the continuous session of a session recorder when a span
+// or log record of a continuous trace reports an error. It is both a span
+// processor and a log processor. Every trigger has its own cooldown, and the
+// reason of the save is stored in the
+// ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON session attribute.
+type TriggerEngine struct {
+	sr             *SessionRecorder
+	cooldowns      map[AutoSaveTrigger]time.Duration
+	minLogSeverity otellog.Severity
+
+	mutex     sync.Mutex
+	lastSaves map[AutoSaveTrigger]time.Time
+	saves     sync.WaitGroup
+}
+
+var _ sdktrace.SpanProcessor = &TriggerEngine{}
+var _ sdklog.Processor = &TriggerEngine{}
+
+func NewTriggerEngine(sr *SessionRecorder, config TriggerEngineConfig) *TriggerEngine {
+	triggers := config.Triggers
+	if triggers == nil {
+		triggers = map[AutoSaveTrigger]time.Duration{
+			AutoSaveTriggerHTTPServerError: 0,
+			AutoSaveTriggerException:       0,
+			AutoSaveTriggerSpanError:       0,
+			AutoSaveTriggerErrorLog:        0,
+		}
+	}
+
+	cooldowns := make(map[AutoSaveTrigger]time.Duration, len(triggers))
+	for trigger, cooldown := range triggers {
+		if cooldown <= 0 {
+			cooldown = defaultAutoSaveCooldown
+		}
+		cooldowns[trigger] = cooldown
+	}
+
+	minLogSeverity := config.MinLogSeverity
+	if minLogSeverity == otellog.SeverityUndefined {
+		minLogSeverity = otellog.SeverityError
+	}
+
+	return &TriggerEngine{
+		sr:             sr,
+		cooldowns:      cooldowns,
+		minLogSeverity: minLogSeverity,
+		lastSaves:      make(map[AutoSaveTrigger]time.Time),
+	}
+}
+
+func (e *TriggerEngine) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {}
+
+// OnEnd fires at most one trigger per span, the most specific one that
+// matches, so a failed request is not saved again by a broader trigger
+// while its own trigger cools down
+func (e *TriggerEngine) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !isContinuousTrace(s.SpanContext().TraceID()) {
+		return
+	}
+
+	if statusCode, ok := httpStatusCode(s.Attributes()); ok && statusCode >= 500 && s.SpanKind() == trace.SpanKindServer && e.enabled(AutoSaveTriggerHTTPServerError) {
+		e.fire(AutoSaveTriggerHTTPServerError, fmt.Sprintf("HTTP %d: %s", statusCode, s.Name()))
+		return
+	}
+
+	for _, event := range s.Events() {
+		if event.Name == semconv.ExceptionEventName && e.enabled(AutoSaveTriggerException) {
+			e.fire(AutoSaveTriggerException, exceptionReason(event.Attributes))
+			return
+		}
+	}
+
+	if s.Status().Code == codes.Error && e.enabled(AutoSaveTriggerSpanError) {
+		reason := s.Name()
+		if s.Status().Description != "" {
+			reason += ": " + s.Status().Description
+		}
+		e.fire(AutoSaveTriggerSpanError, reason)
+	}
+}
+
+func (e *TriggerEngine) OnEmit(ctx context.Context, record *sdklog.Record) error {
+	if record.Severity() < e.minLogSeverity || !isContinuousTrace(record.TraceID()) || !e.enabled(AutoSaveTriggerErrorLog) {
+		return nil
+	}
+
+	reason := record.Body().String()
+	if reason == "" {
+		reason = record.SeverityText()
+	}
+	e.fire(AutoSaveTriggerErrorLog, reason)
+	return nil
+}
+
+// Shutdown waits for the saves in progress
+func (e *TriggerEngine) Shutdown(ctx context.Context) error {
+	return e.ForceFlush(ctx)
+}
+
+// ForceFlush waits for the saves in progress, unless it is called by one of
+// them
+func (e *TriggerEngine) ForceFlush(ctx context.Context) error {
+	if isSaving(ctx) {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		e.saves.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *TriggerEngine) enabled(trigger AutoSaveTrigger) bool {
+	_, ok := e.cooldowns[trigger]
+	return ok
+}
+
+// fire saves the session in the background unless the trigger is cooling down
+func (e *TriggerEngine) fire(trigger AutoSaveTrigger, reason string) {
+	e.mutex.Lock()
+	now := time.Now()
+	if last, ok := e.lastSaves[trigger]; ok && now.Sub(last) < e.cooldowns[trigger] {
+		e.mutex.Unlock()
+		return
+	}
+	e.lastSaves[trigger] = now
+	e.saves.Add(1)
+	e.mutex.Unlock()
+
+	go func() {
+		defer e.saves.Done()
+		e.sr.save(context.Background(), SessionTriggerAutoSave, &Session{
+			SessionAttributes: map[string]interface{}{
+				constants.ATTR_MULTIPLAYER_CONTINUOUS_SESSION_AUTO_SAVE_REASON: fmt.Sprintf("%s: %s", trigger, reason),
+			},
+		})
+	}()
+}
+
+func isContinuousTrace(traceID trace.TraceID) bool {
+	sessionType, _, ok := sdk.ClassifyTrace(traceID.String())
+	return ok && sessionType == types.SESSION_TYPE_CONTINUOUS
+}
+
+// httpStatusCode reads the response status code of an HTTP span using the
+// current or the older semantic conventions
+func httpStatusCode(attrs []attribute.KeyValue) (int64, bool) {
+	for _, attr := range attrs {
+		if attr.Key == semconv.HTTPResponseStatusCodeKey || attr.Key == "http.status_code" {
+			return attr.Value.AsInt64(), true
+		}
+	}
+	return 0, false
+}
+
+func exceptionReason(attrs []attribute.KeyValue) string {
+	var exceptionType, message string
+	for _, attr := range attrs {
+		switch attr.Key {
+		case semconv.ExceptionTypeKey:
+			exceptionType = attr.Value.Emit()
+		case semconv.ExceptionMessageKey:
+			message = attr.Value.Emit()
+		}
+	}
+
+	if exceptionType == "" {
+		return message
+	}
+	if message == "" {
+		return exceptionType
+	}
+	return exceptionType + ": " + message
+}
@@ -0,0 +1,134 @@
+// Package multiplayer wires the Multiplayer session recorder into an
+// OpenTelemetry pipeline with a single call to Setup.
+package multiplayer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	mptrace "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Pipeline is the OpenTelemetry pipeline created by Setup
+type Pipeline struct {
+	TracerProvider  *sdktrace.TracerProvider
+	LoggerProvider  *sdklog.LoggerProvider
+	SessionRecorder *session_recorder.SessionRecorder
+	IDGenerator     *mptrace.SessionRecorderIdGenerator
+	// Shutdown stops the active session, flushes the providers and shuts
+	// them down, see session_recorder.ShutdownCoordinator
+	Shutdown func(ctx context.Context) error
+}
+
+// Setup creates the Multiplayer exporters, the session recorder id generator
+// and sampler, the tracer and logger providers, and an initialized session
+// recorder. Unless disabled with WithGlobal, the providers and a W3C trace
+// context and baggage propagator are registered as the OpenTelemetry globals.
+// Call Pipeline.Shutdown before the process exits.
+func Setup(ctx context.Context, opts ...Option) (*Pipeline, error) {
+	config := newConfig(opts)
+
+	traceExporter, logsExporter, err := newExporters(config)
+	if err != nil {
+		return nil, err
+	}
+
+	idGenerator := mptrace.NewSessionRecorderIdGenerator()
+	sampler := mptrace.NewSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.sampleRatio)))
+
+	traceOptions := []sdktrace.TracerProviderOption{
+		sdktrace.WithIDGenerator(idGenerator),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithBatcher(traceExporter),
+	}
+	logOptions := []sdklog.LoggerProviderOption{
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(logsExporter)),
+	}
+	if config.resource != nil {
+		traceOptions = append(traceOptions, sdktrace.WithResource(config.resource))
+		logOptions = append(logOptions, sdklog.WithResource(config.resource))
+	}
+	if config.secondaryTraceExporter != nil {
+		traceOptions = append(traceOptions, sdktrace.WithBatcher(exporters.NewSessionRecorderTraceExporterWrapper(config.secondaryTraceExporter)))
+	}
+	if config.secondaryLogsExporter != nil {
+		logOptions = append(logOptions, sdklog.WithProcessor(sdklog.NewBatchProcessor(exporters.NewSessionRecorderLogsExporterWrapper(config.secondaryLogsExporter))))
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(traceOptions...)
+	loggerProvider := sdklog.NewLoggerProvider(logOptions...)
+
+	// the id generator and the providers must be the ones of the pipeline, so
+	// they are applied after the options of WithSessionRecorderOptions
+	recorderOptions := append([]session_recorder.Option(nil), config.recorderOptions...)
+	recorderOptions = append(recorderOptions,
+		session_recorder.WithTraceIDGenerator(idGenerator),
+		session_recorder.WithProviders(tracerProvider, loggerProvider),
+	)
+	if config.apiKey != "" {
+		recorderOptions = append(recorderOptions, session_recorder.WithAPIKey(config.apiKey))
+	}
+
+	sr, err := session_recorder.New(recorderOptions...)
+	if err != nil {
+		return nil, errors.Join(err, tracerProvider.Shutdown(ctx), loggerProvider.Shutdown(ctx))
+	}
+
+	if config.global {
+		otel.SetTracerProvider(tracerProvider)
+		global.SetLoggerProvider(loggerProvider)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+			propagation.TraceContext{},
+			propagation.Baggage{},
+		))
+	}
+
+	coordinator := session_recorder.NewShutdownCoordinator(session_recorder.ShutdownConfig{
+		SessionRecorders: []*session_recorder.SessionRecorder{sr},
+		Providers:        []session_recorder.Provider{tracerProvider, loggerProvider},
+	})
+
+	return &Pipeline{
+		TracerProvider:  tracerProvider,
+		LoggerProvider:  loggerProvider,
+		SessionRecorder: sr,
+		IDGenerator:     idGenerator,
+		Shutdown:        coordinator.Shutdown,
+	}, nil
+}
+
+// newExporters creates the Multiplayer exporters of the configured protocol
+func newExporters(config config) (sdktrace.SpanExporter, sdklog.Exporter, error) {
+	switch config.protocol {
+	case ProtocolHTTP:
+		traceExporter, err := exporters.NewSessionRecorderHttpTraceExporter(config.apiKey, config.tracesEndpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		logsExporter, err := exporters.NewSessionRecorderHttpLogsExporter(config.apiKey, config.logsEndpoint)
+		if err != nil {
+			return nil, nil, errors.Join(err, traceExporter.Shutdown(context.Background()))
+		}
+		return traceExporter, logsExporter, nil
+	case ProtocolGRPC:
+		traceExporter, err := exporters.NewSessionRecorderGrpcTraceExporter(config.apiKey, config.tracesEndpoint)
+		if err != nil {
+			return nil, nil, err
+		}
+		logsExporter, err := exporters.NewSessionRecorderGrpcLogsExporter(config.apiKey, config.logsEndpoint)
+		if err != nil {
+			return nil, nil, errors.Join(err, traceExporter.Shutdown(context.Background()))
+		}
+		return traceExporter, logsExporter, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported protocol %q", config.protocol)
+}
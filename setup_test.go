@@ -0,0 +1,143 @@
+package multiplayer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go"
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/exporters/exportertest"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder"
+	"github.com/multiplayer-app/multiplayer-otlp-go/session_recorder/sessionrecordertest"
+	mptrace "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+)
+
+var multiplayerAttribute = attribute.String(constants.ATTR_MULTIPLAYER_HTTP_REQUEST_BODY, "{}")
+
+// otlpServer counts the OTLP export requests by path
+type otlpServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests map[string]int
+}
+
+func newOTLPServer(t *testing.T) *otlpServer {
+	t.Helper()
+
+	s := &otlpServer{requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		s.mutex.Lock()
+		s.requests[r.URL.Path]++
+		s.mutex.Unlock()
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *otlpServer) Requests(path string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests[path]
+}
+
+func TestSetup(t *testing.T) {
+	api := sessionrecordertest.NewServer()
+	defer api.Close()
+	otlp := newOTLPServer(t)
+
+	spans := exportertest.NewTraceExporter()
+	records := exportertest.NewLogsExporter()
+	ctx := context.Background()
+
+	pipeline, err := multiplayer.Setup(ctx,
+		multiplayer.WithAPIKey(sessionrecordertest.APIKey),
+		multiplayer.WithEndpoints(otlp.URL+"/v1/traces", otlp.URL+"/v1/logs"),
+		multiplayer.WithGlobal(false),
+		multiplayer.WithSecondaryExporters(spans, records),
+		multiplayer.WithSessionRecorderOptions(append(api.Options(),
+			// overridden by the id generator of the pipeline
+			session_recorder.WithTraceIDGenerator(mptrace.NewSessionRecorderIdGenerator()),
+			session_recorder.WithAPIKey("other-api-key"),
+		)...),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sr := pipeline.SessionRecorder
+	if err := sr.StartContext(ctx, types.SESSION_TYPE_MANUAL, nil); err != nil {
+		t.Fatal(err)
+	}
+	shortSessionID := sr.ShortSessionID()
+
+	spanCtx, span := pipeline.TracerProvider.Tracer("test").Start(ctx, "request")
+	span.SetAttributes(multiplayerAttribute)
+	var record log.Record
+	record.SetBody(log.StringValue("handled"))
+	record.AddAttributes(log.String(string(multiplayerAttribute.Key), multiplayerAttribute.Value.AsString()))
+	pipeline.LoggerProvider.Logger("test").Emit(spanCtx, record)
+	span.End()
+
+	if _, id, ok := mptrace.Decode(span.SpanContext().TraceID()); !ok || id != shortSessionID {
+		t.Errorf("expected the trace tagged with session %s, got %q", shortSessionID, id)
+	}
+
+	if err := pipeline.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	session, ok := api.Session(shortSessionID)
+	if !ok || session.State != sessionrecordertest.SessionStateStopped {
+		t.Errorf("expected session %s to be stopped by Shutdown, got %+v", shortSessionID, session)
+	}
+	if n := otlp.Requests("/v1/traces"); n == 0 {
+		t.Error("expected the spans exported to Multiplayer")
+	}
+	if n := otlp.Requests("/v1/logs"); n == 0 {
+		t.Error("expected the log records exported to Multiplayer")
+	}
+
+	exported := spans.SpansForSession(shortSessionID)
+	if len(exported) != 1 {
+		t.Fatalf("expected the span exported to the secondary backend, got %d spans", len(exported))
+	}
+	exportertest.AssertNoMultiplayerAttributes(t, exported...)
+
+	// log records are exported to the secondary backend unchanged
+	logged := records.RecordsForSession(shortSessionID)
+	if len(logged) != 1 {
+		t.Fatalf("expected the log record exported to the secondary backend, got %d records", len(logged))
+	}
+	found := false
+	logged[0].WalkAttributes(func(kv log.KeyValue) bool {
+		found = found || kv.Key == string(multiplayerAttribute.Key)
+		return true
+	})
+	if !found {
+		t.Errorf("expected the log record to keep %s", multiplayerAttribute.Key)
+	}
+}
+
+func TestSetupErrors(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv(constants.ENV_MULTIPLAYER_API_KEY, "")
+
+	if _, err := multiplayer.Setup(ctx, multiplayer.WithProtocol("thrift"), multiplayer.WithGlobal(false)); err == nil || !strings.Contains(err.Error(), "thrift") {
+		t.Errorf("expected an unsupported protocol error, got %v", err)
+	}
+
+	_, err := multiplayer.Setup(ctx, multiplayer.WithGlobal(false))
+	if !errors.Is(err, session_recorder.ErrAPIKeyNotProvided) {
+		t.Errorf("expected ErrAPIKeyNotProvided, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package multiplayer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Session trace ids
+//
+// A trace id recorded in a session is written as 32 hex characters:
+//
+//	debdeb 0123456789abcdef 0123456789
+//	prefix short id         random
+//
+// The prefix is "debdeb" for manual and "cdbcdb" for continuous sessions. The
+// short id of 16 lowercase hex characters follows as is, and the remaining 40
+// bits are random. This is the layout read by the Multiplayer backend and the
+// other Multiplayer SDKs, so it is the only one written.
+//
+// Short ids of other alphabets are rejected rather than packed into another
+// layout. The 104 bits after the prefix cannot hold them losslessly and keep
+// trace ids unique: 16 characters of [a-z0-9] alone take 83 bits, leaving 21
+// random bits, so traces of a busy session would collide after a few thousand
+// traces. The backend would not read such a layout either. A new layout would
+// need a prefix of its own, since every value after "debdeb" or "cdbcdb" is a
+// valid short id of this one.
+
+// ErrInvalidSessionId is returned by Encode for short session ids that are not
+// 16 lowercase hex characters
+var ErrInvalidSessionId = errors.New("short session id cannot be encoded in a trace id")
+
+const (
+	prefixLength  = len(constants.MULTIPLAYER_TRACE_DEBUG_PREFIX)
+	shortIdLength = constants.MULTIPLAYER_TRACE_DEBUG_SESSION_SHORT_ID_LENGTH
+)
+
+// Encode returns a new trace id of the session. Every call returns different
+// random bits.
+func Encode(sessionType types.SessionType, sessionShortId string) (trace.TraceID, error) {
+	var prefix string
+	switch sessionType {
+	case types.SESSION_TYPE_MANUAL:
+		prefix = constants.MULTIPLAYER_TRACE_DEBUG_PREFIX
+	case types.SESSION_TYPE_CONTINUOUS:
+		prefix = constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX
+	default:
+		return trace.TraceID{}, fmt.Errorf("unsupported session type %d", sessionType)
+	}
+
+	if !isShortId(sessionShortId) {
+		return trace.TraceID{}, fmt.Errorf("%w: %q", ErrInvalidSessionId, sessionShortId)
+	}
+
+	var tid trace.TraceID
+	n, _ := hex.Decode(tid[:], []byte(prefix+sessionShortId))
+	for i := n; i < len(tid); i++ {
+		tid[i] = byte(rand.Uint32())
+	}
+	return tid, nil
+}
+
+// Decode returns the type and the short id of the session a trace id was
+// encoded for. ok is false for traces recorded outside of sessions.
+func Decode(traceID trace.TraceID) (sessionType types.SessionType, sessionShortId string, ok bool) {
+	traceIdHex := traceID.String()
+
+	switch traceIdHex[:prefixLength] {
+	case constants.MULTIPLAYER_TRACE_DEBUG_PREFIX:
+		sessionType = types.SESSION_TYPE_MANUAL
+	case constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX:
+		sessionType = types.SESSION_TYPE_CONTINUOUS
+	default:
+		return 0, "", false
+	}
+
+	return sessionType, traceIdHex[prefixLength : prefixLength+shortIdLength], true
+}
+
+// isShortId reports whether the short id is 16 lowercase hex characters
+func isShortId(sessionShortId string) bool {
+	if len(sessionShortId) != shortIdLength {
+		return false
+	}
+	for i := 0; i < len(sessionShortId); i++ {
+		c := sessionShortId[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package multiplayer_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	multiplayer "github.com/multiplayer-app/multiplayer-otlp-go/trace"
+	"github.com/multiplayer-app/multiplayer-otlp-go/types"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		sessionType types.SessionType
+		prefix      string
+	}{
+		{"manual", types.SESSION_TYPE_MANUAL, "debdeb"},
+		{"continuous", types.SESSION_TYPE_CONTINUOUS, "cdbcdb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, shortId := range []string{"0123456789abcdef", "0000000000000000", "ffffffffffffffff"} {
+				traceID, err := multiplayer.Encode(tt.sessionType, shortId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := tt.prefix + shortId; !strings.HasPrefix(traceID.String(), want) {
+					t.Errorf("expected trace id %s to start with %s", traceID, want)
+				}
+
+				sessionType, decoded, ok := multiplayer.Decode(traceID)
+				if !ok || sessionType != tt.sessionType || decoded != shortId {
+					t.Errorf("expected %d session %s, got %d session %q (ok %t)", tt.sessionType, shortId, sessionType, decoded, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeRandomBits(t *testing.T) {
+	seen := make(map[trace.TraceID]bool)
+	for i := 0; i < 1000; i++ {
+		traceID, err := multiplayer.Encode(types.SESSION_TYPE_MANUAL, "0123456789abcdef")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[traceID] {
+			t.Fatalf("trace id %s encoded twice", traceID)
+		}
+		seen[traceID] = true
+	}
+}
+
+func TestEncodeRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		shortId string
+	}{
+		{"empty", ""},
+		{"too short", "0123456789abcde"},
+		{"too long", "0123456789abcdef0"},
+		{"uppercase", "0123456789ABCDEF"},
+		{"base36", "0123456789abcdxz"},
+		{"non ascii", "0123456789abcdé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := multiplayer.Encode(types.SESSION_TYPE_MANUAL, tt.shortId)
+			if !errors.Is(err, multiplayer.ErrInvalidSessionId) {
+				t.Errorf("expected ErrInvalidSessionId for %q, got %v", tt.shortId, err)
+			}
+		})
+	}
+
+	if _, err := multiplayer.Encode(types.SessionType(42), "0123456789abcdef"); err == nil {
+		t.Error("expected an error for an unsupported session type")
+	}
+}
+
+func TestDecodeUntagged(t *testing.T) {
+	for _, traceIDHex := range []string{
+		"0123456789abcdef0123456789abcdef",
+		"debde0123456789abcdef0123456789a",
+		"00000000000000000000000000000001",
+	} {
+		traceID, err := trace.TraceIDFromHex(traceIDHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := multiplayer.Decode(traceID); ok {
+			t.Errorf("expected trace id %s not to belong to a session", traceIDHex)
+		}
+	}
+}
+
+func TestSessionRecorderIdGeneratorRejects(t *testing.T) {
+	idGenerator := multiplayer.NewSessionRecorderIdGenerator()
+	if err := idGenerator.ValidateSessionId("0123456789abcdxz", types.SESSION_TYPE_MANUAL); !errors.Is(err, multiplayer.ErrInvalidSessionId) {
+		t.Errorf("expected ErrInvalidSessionId, got %v", err)
+	}
+	if err := idGenerator.ValidateSessionId("0123456789abcdef", types.SESSION_TYPE_CONTINUOUS); err != nil {
+		t.Errorf("expected a hex short id to be accepted, got %v", err)
+	}
+}
@@ -8,16 +8,22 @@ import (
 	"math/rand"
 	"sync"
 
-	"github.com/multiplayer-app/multiplayer-otlp-go/constants"
 	"github.com/multiplayer-app/multiplayer-otlp-go/types"
 	otelTrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
 )
 
+// sessionEntry is a session registered through AddSession
+type sessionEntry struct {
+	sessionShortId string
+	sessionType    types.SessionType
+	match          func(ctx context.Context) bool
+}
+
 type SessionRecorderIdGenerator struct {
 	sessionShortId  string
 	sessionType     types.SessionType
-	generateLongId  func() string
+	sessions        []sessionEntry
 	generateShortId func() string
 	randSource      *rand.Rand
 	mutex           sync.Mutex
@@ -33,8 +39,7 @@ func NewSessionRecorderIdGenerator() *SessionRecorderIdGenerator {
 	return &SessionRecorderIdGenerator{
 		sessionShortId:  "",
 		sessionType:     types.SESSION_TYPE_MANUAL,
-		generateLongId:  getIdGenerator(16, randSource), // 16 bytes = 32 hex chars
-		generateShortId: getIdGenerator(8, randSource),  // 8 bytes = 16 hex chars
+		generateShortId: getIdGenerator(8, randSource), // 8 bytes = 16 hex chars
 		randSource:      randSource,
 	}
 }
@@ -47,64 +52,101 @@ func getIdGenerator(byteLength int, randSource *rand.Rand) func() string {
 	}
 }
 
-func (gen *SessionRecorderIdGenerator) generateTraceId() string {
-	traceId := gen.generateLongId()
+func (gen *SessionRecorderIdGenerator) generateSpanId() string {
+	return gen.generateShortId()
+}
 
-	if gen.sessionShortId != "" {
-		var sessionTypePrefix string
-		switch gen.sessionType {
-		case types.SESSION_TYPE_CONTINUOUS:
-			sessionTypePrefix = constants.MULTIPLAYER_TRACE_CONTINUOUS_DEBUG_PREFIX
-		default:
-			sessionTypePrefix = constants.MULTIPLAYER_TRACE_DEBUG_PREFIX
-		}
+func (gen *SessionRecorderIdGenerator) SetSessionId(sessionShortId string, sessionType types.SessionType) {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
+	gen.sessionShortId = sessionShortId
+	gen.sessionType = sessionType
+}
 
-		prefix := sessionTypePrefix + gen.sessionShortId
+// ValidateSessionId returns an error wrapping ErrInvalidSessionId when traces
+// cannot be tagged with the short session id, see Encode
+func (gen *SessionRecorderIdGenerator) ValidateSessionId(sessionShortId string, sessionType types.SessionType) error {
+	_, err := Encode(sessionType, sessionShortId)
+	return err
+}
+
+// AddSession registers an additional session. A new trace is tagged with the
+// first registered session whose match function accepts the context the trace
+// is started from; a nil match function accepts every context. Sessions are
+// checked in the order they were added, before the session set by SetSessionId.
+func (gen *SessionRecorderIdGenerator) AddSession(sessionShortId string, sessionType types.SessionType, match func(ctx context.Context) bool) {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
 
-		if len(prefix) < len(traceId) {
-			sessionTraceId := prefix + traceId[len(prefix):]
-			return sessionTraceId
+	for i, session := range gen.sessions {
+		if session.sessionShortId == sessionShortId {
+			gen.sessions = append(gen.sessions[:i], gen.sessions[i+1:]...)
+			break
 		}
 	}
-
-	return traceId
+	gen.sessions = append(gen.sessions, sessionEntry{
+		sessionShortId: sessionShortId,
+		sessionType:    sessionType,
+		match:          match,
+	})
 }
 
-func (gen *SessionRecorderIdGenerator) generateSpanId() string {
-	return gen.generateShortId()
+// RemoveSession unregisters a session added with AddSession
+func (gen *SessionRecorderIdGenerator) RemoveSession(sessionShortId string) {
+	gen.mutex.Lock()
+	defer gen.mutex.Unlock()
+
+	for i, session := range gen.sessions {
+		if session.sessionShortId == sessionShortId {
+			gen.sessions = append(gen.sessions[:i], gen.sessions[i+1:]...)
+			return
+		}
+	}
 }
 
-func (gen *SessionRecorderIdGenerator) SetSessionId(sessionShortId string, sessionType types.SessionType) {
+// selectSession picks the session for a trace started from ctx. A session
+// bound to ctx with types.ContextWithSession always wins, even while it is not
+// recording. Match functions and bound sessions are called without holding
+// the mutex.
+func (gen *SessionRecorderIdGenerator) selectSession(ctx context.Context) (string, types.SessionType) {
+	if session, ok := types.SessionFromContext(ctx); ok {
+		sessionShortId, sessionType, active := session.ActiveSession()
+		if !active {
+			return "", types.SESSION_TYPE_MANUAL
+		}
+		return sessionShortId, sessionType
+	}
+
 	gen.mutex.Lock()
-	defer gen.mutex.Unlock()
-	gen.sessionShortId = sessionShortId
-	gen.sessionType = sessionType
+	sessions := make([]sessionEntry, len(gen.sessions))
+	copy(sessions, gen.sessions)
+	sessionShortId, sessionType := gen.sessionShortId, gen.sessionType
+	gen.mutex.Unlock()
+
+	for _, session := range sessions {
+		if session.match == nil || session.match(ctx) {
+			return session.sessionShortId, session.sessionType
+		}
+	}
+
+	return sessionShortId, sessionType
 }
 
 func (gen *SessionRecorderIdGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	sessionShortId, sessionType := gen.selectSession(ctx)
+
 	gen.mutex.Lock()
 	defer gen.mutex.Unlock()
 
 	var tid trace.TraceID
 	var sid trace.SpanID
 
-	if gen.sessionShortId != "" {
-		traceIdHex := gen.generateTraceId()
-		traceIdBytes, err := hex.DecodeString(traceIdHex)
-		if err == nil && len(traceIdBytes) == 16 {
-			copy(tid[:], traceIdBytes)
-		}
+	if sessionShortId != "" {
+		// short ids are validated before sessions start
+		tid, _ = Encode(sessionType, sessionShortId)
+	}
 
-		if !tid.IsValid() {
-			for {
-				binary.NativeEndian.PutUint64(tid[:8], gen.randSource.Uint64())
-				binary.NativeEndian.PutUint64(tid[8:], gen.randSource.Uint64())
-				if tid.IsValid() {
-					break
-				}
-			}
-		}
-	} else {
+	if !tid.IsValid() {
 		for {
 			binary.NativeEndian.PutUint64(tid[:8], gen.randSource.Uint64())
 			binary.NativeEndian.PutUint64(tid[8:], gen.randSource.Uint64())
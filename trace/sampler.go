@@ -1,7 +1,6 @@
 package multiplayer
 
 import (
-	"github.com/multiplayer-app/multiplayer-otlp-go/sdk"
 	"go.opentelemetry.io/otel/sdk/trace"
 	trace_ "go.opentelemetry.io/otel/trace"
 )
@@ -21,7 +20,7 @@ func NewSampler(baseSampler trace.Sampler) trace.Sampler {
 }
 
 func (ts traceIDBasedSampler) ShouldSample(p trace.SamplingParameters) trace.SamplingResult {
-	if sdk.IsMultiplayerTrace(p.TraceID.String()) {
+	if _, _, ok := Decode(p.TraceID); ok {
 		return trace.SamplingResult{
 			Decision:   trace.RecordAndSample,
 			Tracestate: trace_.SpanContextFromContext(p.ParentContext).TraceState(),
@@ -0,0 +1,27 @@
+package types
+
+import "context"
+
+// ContextSession is a session that can be bound to a context so that only
+// traces started from that context are tagged with it
+type ContextSession interface {
+	// ActiveSession returns the short id and type of the session. ok is false
+	// when the session is not recording.
+	ActiveSession() (sessionShortId string, sessionType SessionType, ok bool)
+}
+
+type contextSessionKey struct{}
+
+// ContextWithSession returns a copy of ctx bound to session
+func ContextWithSession(ctx context.Context, session ContextSession) context.Context {
+	return context.WithValue(ctx, contextSessionKey{}, session)
+}
+
+// SessionFromContext returns the session bound to ctx
+func SessionFromContext(ctx context.Context) (ContextSession, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	session, ok := ctx.Value(contextSessionKey{}).(ContextSession)
+	return session, ok && session != nil
+}